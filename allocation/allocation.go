@@ -0,0 +1,176 @@
+package allocation
+
+import (
+	"slices"
+	"time"
+)
+
+// Allocation is one collected row of an aggregation, with the same values as the
+// CSV record. Efficiencies are percentages, as in the CSVs.
+type Allocation struct {
+	Aggregation string `json:"aggregation"` // e.g. "Pod", "Namespace", "Rollout"
+	Name        string `json:"name"`
+	Cluster     string `json:"cluster"`
+	Region      string `json:"region"`
+	Namespace   string `json:"namespace"`
+	WindowStart string `json:"windowStart"`
+	WindowEnd   string `json:"windowEnd"`
+
+	CpuCost          float64 `json:"cpuCost"`
+	GpuCost          float64 `json:"gpuCost"`
+	RamCost          float64 `json:"ramCost"`
+	PVCost           float64 `json:"pvCost"`
+	NetworkCost      float64 `json:"networkCost"`
+	LoadBalancerCost float64 `json:"loadBalancerCost"`
+	TotalCost        float64 `json:"totalCost"`
+
+	CpuEfficiency   float64 `json:"cpuEfficiency"`
+	RamEfficiency   float64 `json:"ramEfficiency"`
+	TotalEfficiency float64 `json:"totalEfficiency"`
+
+	// Cost of the unused CPU and RAM requests, see Waste.
+	CpuWaste float64 `json:"cpuWaste"`
+	RamWaste float64 `json:"ramWaste"`
+
+	// Average requests and usage over the window, in cores and bytes. They are only
+	// collected for the Deployment, Controller and Rollout aggregations and are not
+	// written by the database sinks.
+	CpuCoreRequestAverage  float64 `json:"cpuCoreRequestAverage,omitempty"`
+	CpuCoreUsageAverage    float64 `json:"cpuCoreUsageAverage,omitempty"`
+	RamBytesRequestAverage float64 `json:"ramBytesRequestAverage,omitempty"`
+	RamBytesUsageAverage   float64 `json:"ramBytesUsageAverage,omitempty"`
+
+	// Properties is the properties object of the Kubecost allocation as returned by
+	// the API (labels, annotations, node, controller, services, container, ...).
+	// It is not part of the CSV records.
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Waste returns the part of a cost that pays for unused requests: cost × (1 − efficiency),
+// efficiency being a percentage. Usage above the request is not waste.
+func Waste(cost, efficiency float64) float64 {
+	if efficiency >= 100 {
+		return 0
+	}
+	return cost * (1 - efficiency/100)
+}
+
+// AverageKeys are the fields of a Kubecost allocation that rightsizing reads, in the
+// order Averages returns them.
+var AverageKeys = []string{"cpuCoreRequestAverage", "cpuCoreUsageAverage", "ramByteRequestAverage", "ramByteUsageAverage"}
+
+// Averages returns the request and usage averages of a Kubecost allocation. Keys it
+// lacks read as 0 and are appended to missing once, so the caller can log them.
+func Averages(element map[string]interface{}, missing *[]string) (cpuRequest, cpuUsage, ramRequest, ramUsage float64) {
+	var values [4]float64
+	for i, key := range AverageKeys {
+		value, ok := element[key].(float64)
+		if !ok && !slices.Contains(*missing, key) {
+			*missing = append(*missing, key)
+		}
+		values[i] = value
+	}
+	return values[0], values[1], values[2], values[3]
+}
+
+// Key identifies an allocation across runs: the same cluster, namespace, name and
+// window always produce the same key. Aggregations without a namespace leave it empty.
+func (a Allocation) Key() string {
+	return a.Aggregation + "/" + a.Cluster + "/" + a.Namespace + "/" + a.Name + "/" + a.WindowStart + "," + a.WindowEnd
+}
+
+// Merge sums the allocations that have the same key, e.g. the Rollout rows of the
+// replica sets of one rollout, and keeps the others as they are, in their order.
+// Efficiencies are averaged weighted by their cost, and the properties of the
+// first allocation are kept.
+func Merge(allocations []Allocation) []Allocation {
+	return MergeBy(allocations, Allocation.Key)
+}
+
+// MergeBy is Merge with another identity, e.g. the label set of a metric series.
+func MergeBy(allocations []Allocation, key func(Allocation) string) []Allocation {
+	index := map[string]int{}
+	var merged []Allocation
+	for _, a := range allocations {
+		k := key(a)
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(merged)
+			merged = append(merged, a)
+			continue
+		}
+		m := &merged[i]
+		m.CpuEfficiency = weighted(m.CpuEfficiency, m.CpuCost, a.CpuEfficiency, a.CpuCost)
+		m.RamEfficiency = weighted(m.RamEfficiency, m.RamCost, a.RamEfficiency, a.RamCost)
+		m.TotalEfficiency = weighted(m.TotalEfficiency, m.CpuCost+m.RamCost, a.TotalEfficiency, a.CpuCost+a.RamCost)
+		m.CpuCost += a.CpuCost
+		m.GpuCost += a.GpuCost
+		m.RamCost += a.RamCost
+		m.PVCost += a.PVCost
+		m.NetworkCost += a.NetworkCost
+		m.LoadBalancerCost += a.LoadBalancerCost
+		m.TotalCost += a.TotalCost
+		m.CpuWaste += a.CpuWaste
+		m.RamWaste += a.RamWaste
+		m.CpuCoreRequestAverage += a.CpuCoreRequestAverage
+		m.CpuCoreUsageAverage += a.CpuCoreUsageAverage
+		m.RamBytesRequestAverage += a.RamBytesRequestAverage
+		m.RamBytesUsageAverage += a.RamBytesUsageAverage
+	}
+	return merged
+}
+
+// weighted averages two efficiencies by their costs.
+func weighted(x, xCost, y, yCost float64) float64 {
+	if xCost+yCost == 0 {
+		return (x + y) / 2
+	}
+	return (x*xCost + y*yCost) / (xCost + yCost)
+}
+
+// Kind is the type of a Field.
+type Kind int
+
+const (
+	String Kind = iota
+	Float
+	Time
+)
+
+// Field describes one column of an allocation for the database sinks.
+type Field struct {
+	Name  string // snake_case column name
+	Kind  Kind
+	Value func(a Allocation) interface{} // string, float64 or time.Time
+}
+
+// Fields lists the columns written by the database sinks, key fields first.
+var Fields = []Field{
+	{"name", String, func(a Allocation) interface{} { return a.Name }},
+	{"cluster", String, func(a Allocation) interface{} { return a.Cluster }},
+	{"namespace", String, func(a Allocation) interface{} { return a.Namespace }},
+	{"window_start", Time, func(a Allocation) interface{} { return parseTime(a.WindowStart) }},
+	{"window_end", Time, func(a Allocation) interface{} { return parseTime(a.WindowEnd) }},
+	{"region", String, func(a Allocation) interface{} { return a.Region }},
+	{"cpu_cost", Float, func(a Allocation) interface{} { return a.CpuCost }},
+	{"gpu_cost", Float, func(a Allocation) interface{} { return a.GpuCost }},
+	{"ram_cost", Float, func(a Allocation) interface{} { return a.RamCost }},
+	{"pv_cost", Float, func(a Allocation) interface{} { return a.PVCost }},
+	{"network_cost", Float, func(a Allocation) interface{} { return a.NetworkCost }},
+	{"load_balancer_cost", Float, func(a Allocation) interface{} { return a.LoadBalancerCost }},
+	{"total_cost", Float, func(a Allocation) interface{} { return a.TotalCost }},
+	{"cpu_efficiency", Float, func(a Allocation) interface{} { return a.CpuEfficiency }},
+	{"ram_efficiency", Float, func(a Allocation) interface{} { return a.RamEfficiency }},
+	{"total_efficiency", Float, func(a Allocation) interface{} { return a.TotalEfficiency }},
+	{"cpu_waste", Float, func(a Allocation) interface{} { return a.CpuWaste }},
+	{"ram_waste", Float, func(a Allocation) interface{} { return a.RamWaste }},
+}
+
+// KeyFields is the number of leading Fields (name, cluster, namespace, window)
+// that identify a row, as Key does; the database sinks upsert on them.
+const KeyFields = 5
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
@@ -0,0 +1,270 @@
+package anomaly
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/storage"
+	"math"
+	"sort"
+)
+
+// Methods that can be given a threshold in Options.Thresholds.
+const (
+	// ZScore compares the cost with the mean of the history, in standard deviations.
+	// Any change from a history without variation reaches the threshold, so
+	// Options.MinChange decides whether it is reported.
+	ZScore = "zscore"
+	// Percent compares the cost with the previous window, in percent. Objects without
+	// a row for the window right before are skipped.
+	Percent = "percent"
+	// Median compares the cost with the median of the history, in percent.
+	Median = "median"
+)
+
+// Options configure the detection.
+type Options struct {
+	// Thresholds enables a method by giving it a threshold. A window is an anomaly
+	// when the absolute score of a method reaches its threshold.
+	Thresholds map[string]float64
+	// HistoryWindows is the number of previous windows compared with.
+	HistoryWindows int
+	// MinHistory is the number of previous windows the z-score and median need.
+	MinHistory int
+	// MinCost skips objects whose cost and expected cost are both below it.
+	MinCost float64
+	// MinChange skips windows whose cost differs from the expected cost by less
+	// than this percent of it.
+	MinChange float64
+}
+
+// Validate reports unknown methods.
+func (o Options) Validate() error {
+	for method := range o.Thresholds {
+		switch method {
+		case ZScore, Percent, Median:
+		default:
+			return fmt.Errorf("unknown anomaly method %q", method)
+		}
+	}
+	return nil
+}
+
+// Anomaly is a window whose cost differs from the history of its object.
+type Anomaly struct {
+	Aggregation string  `json:"aggregation"`
+	Cluster     string  `json:"cluster"`
+	Namespace   string  `json:"namespace"`
+	Name        string  `json:"name"`
+	WindowStart string  `json:"windowStart"`
+	Method      string  `json:"method"`
+	Cost        float64 `json:"cost"`
+	Expected    float64 `json:"expected"` // mean, previous cost or median of the history
+	Score       float64 `json:"score"`    // standard deviations or percent; negative for drops
+	Threshold   float64 `json:"threshold"`
+	History     int     `json:"history"` // previous windows compared with
+}
+
+func (a Anomaly) String() string {
+	unit := "%"
+	if a.Method == ZScore {
+		unit = " standard deviations"
+	}
+	name := a.Name
+	if a.Namespace != "" && a.Aggregation != "Namespace" {
+		name = a.Namespace + "/" + a.Name
+	}
+	return fmt.Sprintf("%s %s cost %.2f, expected %.2f (%s %+.1f%s)", a.Aggregation, name, a.Cost, a.Expected, a.Method, a.Score, unit)
+}
+
+// Run reads the stored history of each aggregation and detects the anomalies of the
+// window starting at windowStart.
+func Run(store storage.Backend, aggregations []string, windowStart string, o Options) ([]Anomaly, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	var anomalies []Anomaly
+	for _, aggregation := range aggregations {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, Detect(allocations, windowStart, o)...)
+	}
+	return anomalies, nil
+}
+
+// Detect compares the window starting at windowStart of every object (cluster,
+// namespace and name) in allocations with the previous windows of the same object.
+// The allocations must be of one aggregation. Objects without a row for the window,
+// or without history, are skipped.
+func Detect(allocations []allocation.Allocation, windowStart string, o Options) []Anomaly {
+	type object struct {
+		current *allocation.Allocation
+		history []allocation.Allocation
+	}
+	objects := map[[3]string]*object{}
+	for i, a := range allocations {
+		key := [3]string{a.Cluster, a.Namespace, a.Name}
+		obj, ok := objects[key]
+		if !ok {
+			obj = &object{}
+			objects[key] = obj
+		}
+		switch {
+		case a.WindowStart == windowStart:
+			obj.current = &allocations[i]
+		case a.WindowStart < windowStart:
+			obj.history = append(obj.history, a)
+		}
+	}
+
+	// Anomalies are sorted by the size of the difference, largest first.
+	var anomalies []Anomaly
+	for _, obj := range objects {
+		if obj.current == nil || len(obj.history) == 0 {
+			continue
+		}
+		sort.Slice(obj.history, func(i, j int) bool { return obj.history[i].WindowStart < obj.history[j].WindowStart })
+		if o.HistoryWindows > 0 && len(obj.history) > o.HistoryWindows {
+			obj.history = obj.history[len(obj.history)-o.HistoryWindows:]
+		}
+		costs := make([]float64, len(obj.history))
+		for i, a := range obj.history {
+			costs[i] = a.TotalCost
+		}
+
+		for _, method := range methods(o.Thresholds) {
+			// After a gap in the history the last row is not the previous window.
+			if method == Percent && obj.history[len(obj.history)-1].WindowEnd != windowStart {
+				continue
+			}
+			expected, score, ok := evaluate(method, obj.current.TotalCost, costs, o.MinHistory)
+			if !ok || (obj.current.TotalCost < o.MinCost && expected < o.MinCost) {
+				continue
+			}
+			if math.Abs(obj.current.TotalCost-expected) < expected*o.MinChange/100 {
+				continue
+			}
+			if math.Abs(score) < o.Thresholds[method] {
+				continue
+			}
+			if math.IsInf(score, 0) {
+				// JSON cannot encode infinity, so the score is capped at the threshold.
+				score = math.Copysign(o.Thresholds[method], score)
+			}
+			anomalies = append(anomalies, Anomaly{
+				Aggregation: obj.current.Aggregation,
+				Cluster:     obj.current.Cluster,
+				Namespace:   obj.current.Namespace,
+				Name:        obj.current.Name,
+				WindowStart: obj.current.WindowStart,
+				Method:      method,
+				Cost:        obj.current.TotalCost,
+				Expected:    expected,
+				Score:       score,
+				Threshold:   o.Thresholds[method],
+				History:     len(costs),
+			})
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		di := math.Abs(anomalies[i].Cost - anomalies[i].Expected)
+		dj := math.Abs(anomalies[j].Cost - anomalies[j].Expected)
+		if di != dj {
+			return di > dj
+		}
+		ki := [4]string{anomalies[i].Cluster, anomalies[i].Namespace, anomalies[i].Name, anomalies[i].Method}
+		kj := [4]string{anomalies[j].Cluster, anomalies[j].Namespace, anomalies[j].Name, anomalies[j].Method}
+		for n := range ki {
+			if ki[n] != kj[n] {
+				return ki[n] < kj[n]
+			}
+		}
+		return false
+	})
+	return anomalies
+}
+
+// methods returns the enabled methods in a stable order.
+func methods(thresholds map[string]float64) []string {
+	var names []string
+	for name := range thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// evaluate returns the expected cost and the score of cost for a method, and
+// false when the history is too short or the score is undefined.
+func evaluate(method string, cost float64, history []float64, minHistory int) (expected, score float64, ok bool) {
+	switch method {
+	case ZScore:
+		if len(history) < minHistory || len(history) < 2 {
+			return 0, 0, false
+		}
+		mean, stddev := meanStddev(history)
+		if stddev == 0 {
+			// Any change from a flat history is infinitely many standard deviations.
+			if cost == mean {
+				return mean, 0, true
+			}
+			return mean, math.Copysign(math.Inf(1), cost-mean), true
+		}
+		return mean, (cost - mean) / stddev, true
+	case Percent:
+		previous := history[len(history)-1]
+		if previous == 0 {
+			return 0, 0, false
+		}
+		return previous, (cost - previous) / previous * 100, true
+	case Median:
+		if len(history) < minHistory {
+			return 0, 0, false
+		}
+		m := median(history)
+		if m == 0 {
+			return 0, 0, false
+		}
+		return m, (cost - m) / m * 100, true
+	}
+	return 0, 0, false
+}
+
+func meanStddev(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(values)-1))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Header is the header of the anomalies CSV.
+var Header = []string{"Aggregation", "ClusterName", "Namespace", "Name", "Window Start", "Method", "Cost", "Expected Cost", "Score", "Threshold", "History"}
+
+// Records returns the anomalies as CSV records, without the header.
+func Records(anomalies []Anomaly) [][]string {
+	records := make([][]string, 0, len(anomalies))
+	for _, a := range anomalies {
+		records = append(records, []string{
+			a.Aggregation, a.Cluster, a.Namespace, a.Name, a.WindowStart, a.Method,
+			fmt.Sprintf("%f", a.Cost), fmt.Sprintf("%f", a.Expected),
+			fmt.Sprintf("%f", a.Score), fmt.Sprintf("%f", a.Threshold), fmt.Sprint(a.History),
+		})
+	}
+	return records
+}
@@ -0,0 +1,168 @@
+package anomaly
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"math"
+	"testing"
+)
+
+// days returns one Namespace row per cost, for consecutive days of October 2024.
+// The last cost is the window being checked.
+func days(name string, costs ...float64) []allocation.Allocation {
+	var allocations []allocation.Allocation
+	for i, cost := range costs {
+		allocations = append(allocations, allocation.Allocation{
+			Aggregation: "Namespace",
+			Name:        name,
+			Cluster:     "prod",
+			WindowStart: fmt.Sprintf("2024-10-%02dT00:00:00Z", i+1),
+			WindowEnd:   fmt.Sprintf("2024-10-%02dT00:00:00Z", i+2),
+			TotalCost:   cost,
+		})
+	}
+	return allocations
+}
+
+func windowOf(allocations []allocation.Allocation) string {
+	return allocations[len(allocations)-1].WindowStart
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds map[string]float64
+		costs      []float64
+		minChange  float64
+		want       []string // methods that report the window
+		score      float64  // score of the first anomaly
+	}{
+		{
+			name:       "zscore",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 12, 10, 12, 10, 12, 40},
+			want:       []string{ZScore},
+		},
+		{
+			name:       "zscore within threshold",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 12, 10, 12, 10, 12, 12},
+		},
+		{
+			name:       "flat history small change",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 10, 10, 10, 10, 10, 10.5},
+			minChange:  20,
+		},
+		{
+			name:       "flat history large change",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 10, 10, 10, 10, 10, 20},
+			minChange:  20,
+			want:       []string{ZScore},
+			score:      3, // infinite, capped at the threshold
+		},
+		{
+			name:       "flat history drop",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 10, 10, 10, 10, 10, 0},
+			minChange:  20,
+			want:       []string{ZScore},
+			score:      -3,
+		},
+		{
+			name:       "zscore short history",
+			thresholds: map[string]float64{ZScore: 3},
+			costs:      []float64{10, 12, 40},
+		},
+		{
+			name:       "percent",
+			thresholds: map[string]float64{Percent: 50},
+			costs:      []float64{10, 16},
+			want:       []string{Percent},
+			score:      60,
+		},
+		{
+			name:       "percent from zero",
+			thresholds: map[string]float64{Percent: 50},
+			costs:      []float64{0, 16},
+		},
+		{
+			name:       "median",
+			thresholds: map[string]float64{Median: 50},
+			costs:      []float64{10, 100, 10, 10, 10, 10, 10, 5},
+			want:       []string{Median},
+			score:      -50,
+		},
+		{
+			name:       "below min cost",
+			thresholds: map[string]float64{Percent: 50},
+			costs:      []float64{0.2, 0.8},
+		},
+		{
+			name:       "all methods",
+			thresholds: map[string]float64{ZScore: 3, Percent: 50, Median: 50},
+			costs:      []float64{10, 12, 10, 12, 10, 12, 11, 40},
+			want:       []string{Median, Percent, ZScore},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations := days("payments", tt.costs...)
+			anomalies := Detect(allocations, windowOf(allocations), Options{
+				Thresholds:     tt.thresholds,
+				HistoryWindows: 14,
+				MinHistory:     6,
+				MinCost:        1,
+				MinChange:      tt.minChange,
+			})
+			if len(anomalies) != len(tt.want) {
+				t.Fatalf("got %v, want %v", anomalies, tt.want)
+			}
+			for i, a := range anomalies {
+				if a.Method != tt.want[i] {
+					t.Errorf("anomaly %d: method %s, want %s", i, a.Method, tt.want[i])
+				}
+				if math.IsInf(a.Score, 0) || math.IsNaN(a.Score) {
+					t.Errorf("anomaly %d: score %g", i, a.Score)
+				}
+			}
+			if tt.score != 0 && math.Abs(anomalies[0].Score-tt.score) > 1e-9 {
+				t.Errorf("score %g, want %g", anomalies[0].Score, tt.score)
+			}
+		})
+	}
+}
+
+func TestDetectSkipsGaps(t *testing.T) {
+	allocations := days("payments", 10, 10, 40)
+	// The day before the window was not collected.
+	allocations = append(allocations[:1], allocations[2])
+	if anomalies := Detect(allocations, windowOf(allocations), Options{Thresholds: map[string]float64{Percent: 50}}); len(anomalies) != 0 {
+		t.Errorf("got %v after a gap", anomalies)
+	}
+}
+
+func TestDetectSeparatesObjects(t *testing.T) {
+	allocations := append(days("payments", 10, 10, 10), days("search", 10, 10, 30)...)
+	// The same name in another cluster has its own history.
+	staging := days("payments", 30, 30, 30)
+	for i := range staging {
+		staging[i].Cluster = "staging"
+	}
+	allocations = append(allocations, staging...)
+
+	anomalies := Detect(allocations, windowOf(allocations), Options{Thresholds: map[string]float64{Percent: 50}})
+	if len(anomalies) != 1 || anomalies[0].Name != "search" || anomalies[0].Expected != 10 {
+		t.Errorf("got %v, want search only", anomalies)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Options{Thresholds: map[string]float64{ZScore: 3, Percent: 50, Median: 50}}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (Options{Thresholds: map[string]float64{"mad": 3}}).Validate(); err == nil {
+		t.Error("unknown method accepted")
+	}
+}
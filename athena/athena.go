@@ -0,0 +1,128 @@
+package athena
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Column is a single Athena column derived from a CSV header entry.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Columns maps the CSV header of an aggregation to Athena columns. Cost and
+// efficiency columns, the request and usage averages and the waste are typed as
+// double, everything else is kept as a string.
+func Columns(header []string) []Column {
+	columns := make([]Column, 0, len(header))
+	for _, h := range header {
+		columnType := "string"
+		if strings.HasSuffix(h, "Cost") || strings.HasSuffix(h, "Efficiency") || strings.HasSuffix(h, "Average") || strings.HasSuffix(h, "Waste") {
+			columnType = "double"
+		}
+		columns = append(columns, Column{Name: schema.ColumnName(h), Type: columnType})
+	}
+	return columns
+}
+
+// TableName returns the Athena table name of an aggregation, e.g. "controller_kind".
+func TableName(aggregation string) string {
+	return schema.ColumnName(aggregation)
+}
+
+// Location returns the S3 prefix holding the objects of an aggregation.
+func Location(aggregation string) string {
+	return fmt.Sprintf("s3://%s/%s/", configs.BucketName, aggregation)
+}
+
+// tableProperties returns the TBLPROPERTIES of an aggregation's table, including
+// the partition projection settings when the month partitioned layout is used.
+func tableProperties(aggregation string) map[string]string {
+	props := map[string]string{}
+	if configs.AthenaTableFormat != "parquet" {
+		props["skip.header.line.count"] = "1"
+	}
+	if configs.PartitionByMonth {
+		props["projection.enabled"] = "true"
+		props["projection.month.type"] = "date"
+		props["projection.month.format"] = "yyyy-MM"
+		props["projection.month.range"] = configs.PartitionProjectionStart + ",NOW"
+		props["projection.month.interval"] = "1"
+		props["projection.month.interval.unit"] = "MONTHS"
+		props["storage.location.template"] = Location(aggregation) + "month=${month}/"
+	}
+	return props
+}
+
+// CreateTableStatement returns the CREATE EXTERNAL TABLE statement of an aggregation.
+func CreateTableStatement(aggregation string) (string, error) {
+	header := schema.Header(aggregation)
+	if header == nil {
+		return "", fmt.Errorf("unknown aggregation %q", aggregation)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE EXTERNAL TABLE IF NOT EXISTS `%s`.`%s` (\n", configs.AthenaDatabase, TableName(aggregation))
+	columns := Columns(header)
+	for i, c := range columns {
+		sep := ","
+		if i == len(columns)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "  `%s` %s%s\n", c.Name, c.Type, sep)
+	}
+	b.WriteString(")\n")
+	if configs.PartitionByMonth {
+		b.WriteString("PARTITIONED BY (`month` string)\n")
+	}
+	if configs.AthenaTableFormat == "parquet" {
+		b.WriteString("STORED AS PARQUET\n")
+	} else {
+		b.WriteString("ROW FORMAT SERDE 'org.apache.hadoop.hive.serde2.OpenCSVSerde'\n")
+		b.WriteString("WITH SERDEPROPERTIES ('separatorChar' = ',', 'quoteChar' = '\"')\n")
+		b.WriteString("STORED AS TEXTFILE\n")
+	}
+	fmt.Fprintf(&b, "LOCATION '%s'\n", Location(aggregation))
+
+	props := tableProperties(aggregation)
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.WriteString("TBLPROPERTIES (\n")
+	for i, k := range keys {
+		sep := ","
+		if i == len(keys)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "  '%s' = '%s'%s\n", k, props[k], sep)
+	}
+	b.WriteString(");\n")
+	return b.String(), nil
+}
+
+// WriteDDL writes the CREATE EXTERNAL TABLE statement of every aggregation to Output/Athena/<Aggregation>.sql.
+func WriteDDL() error {
+	dir := filepath.Join(configs.OutputDir, "Athena")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, aggregation := range schema.Aggregations {
+		ddl, err := CreateTableStatement(aggregation)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, aggregation+".sql"), []byte(ddl), 0644); err != nil {
+			return err
+		}
+	}
+	configs.InfoLogger.Println("Athena table definitions written to", dir)
+	return nil
+}
@@ -0,0 +1,112 @@
+package athena
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+)
+
+// GlueAPI is the subset of the Glue client used to register the tables.
+// *glue.Glue satisfies it; tests can substitute a fake.
+type GlueAPI interface {
+	GetDatabase(*glue.GetDatabaseInput) (*glue.GetDatabaseOutput, error)
+	CreateDatabase(*glue.CreateDatabaseInput) (*glue.CreateDatabaseOutput, error)
+	GetTable(*glue.GetTableInput) (*glue.GetTableOutput, error)
+	CreateTable(*glue.CreateTableInput) (*glue.CreateTableOutput, error)
+	UpdateTable(*glue.UpdateTableInput) (*glue.UpdateTableOutput, error)
+}
+
+// TableInput builds the Glue definition of an aggregation's table. It describes
+// the same table as CreateTableStatement.
+func TableInput(aggregation string) *glue.TableInput {
+	var columns []*glue.Column
+	for _, c := range Columns(schema.Header(aggregation)) {
+		columns = append(columns, &glue.Column{Name: aws.String(c.Name), Type: aws.String(c.Type)})
+	}
+
+	params := map[string]*string{
+		"EXTERNAL":       aws.String("TRUE"),
+		"classification": aws.String(configs.AthenaTableFormat),
+	}
+	for k, v := range tableProperties(aggregation) {
+		params[k] = aws.String(v)
+	}
+
+	sd := &glue.StorageDescriptor{
+		Columns:  columns,
+		Location: aws.String(Location(aggregation)),
+	}
+	if configs.AthenaTableFormat == "parquet" {
+		sd.InputFormat = aws.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetInputFormat")
+		sd.OutputFormat = aws.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetOutputFormat")
+		sd.SerdeInfo = &glue.SerDeInfo{
+			SerializationLibrary: aws.String("org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe"),
+		}
+	} else {
+		sd.InputFormat = aws.String("org.apache.hadoop.mapred.TextInputFormat")
+		sd.OutputFormat = aws.String("org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat")
+		sd.SerdeInfo = &glue.SerDeInfo{
+			SerializationLibrary: aws.String("org.apache.hadoop.hive.serde2.OpenCSVSerde"),
+			Parameters: map[string]*string{
+				"separatorChar": aws.String(","),
+				"quoteChar":     aws.String("\""),
+			},
+		}
+	}
+
+	input := &glue.TableInput{
+		Name:              aws.String(TableName(aggregation)),
+		TableType:         aws.String("EXTERNAL_TABLE"),
+		Parameters:        params,
+		StorageDescriptor: sd,
+	}
+	if configs.PartitionByMonth {
+		input.PartitionKeys = []*glue.Column{{Name: aws.String("month"), Type: aws.String("string")}}
+	}
+	return input
+}
+
+// RegisterTables creates the database if needed and creates or updates the table of every aggregation.
+func RegisterTables(api GlueAPI) error {
+	_, err := api.GetDatabase(&glue.GetDatabaseInput{Name: aws.String(configs.AthenaDatabase)})
+	if isNotFound(err) {
+		_, err = api.CreateDatabase(&glue.CreateDatabaseInput{
+			DatabaseInput: &glue.DatabaseInput{Name: aws.String(configs.AthenaDatabase)},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, aggregation := range schema.Aggregations {
+		input := TableInput(aggregation)
+		_, err := api.GetTable(&glue.GetTableInput{
+			DatabaseName: aws.String(configs.AthenaDatabase),
+			Name:         input.Name,
+		})
+		switch {
+		case err == nil:
+			_, err = api.UpdateTable(&glue.UpdateTableInput{
+				DatabaseName: aws.String(configs.AthenaDatabase),
+				TableInput:   input,
+			})
+		case isNotFound(err):
+			_, err = api.CreateTable(&glue.CreateTableInput{
+				DatabaseName: aws.String(configs.AthenaDatabase),
+				TableInput:   input,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		configs.InfoLogger.Println("Glue table registered:", *input.Name)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == glue.ErrCodeEntityNotFoundException
+}
@@ -0,0 +1,323 @@
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/storage"
+	"kubecost-efficiency-fetcher/webhook"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Validate reports budgets that cannot be evaluated.
+func Validate(budgets []configs.Budget) error {
+	names := map[string]bool{}
+	for _, b := range budgets {
+		if b.Name == "" || names[b.Name] {
+			return fmt.Errorf("budget names must be set and unique: %q", b.Name)
+		}
+		names[b.Name] = true
+		switch b.Scope {
+		case "namespace", "cluster":
+		case "label":
+			if !strings.Contains(b.Match, "=") {
+				return fmt.Errorf("budget %s: label budgets match key=value, not %q", b.Name, b.Match)
+			}
+		default:
+			return fmt.Errorf("budget %s: unknown scope %q", b.Name, b.Scope)
+		}
+		if b.Period != "monthly" && b.Period != "daily" {
+			return fmt.Errorf("budget %s: unknown period %q", b.Name, b.Period)
+		}
+		if b.Amount <= 0 {
+			return fmt.Errorf("budget %s: amount must be positive", b.Name)
+		}
+	}
+	return nil
+}
+
+// Sink sums the cost of the pods of every label budget in the collected Window.
+// Labels are not kept in the CSV history, so label budgets are tracked from the
+// runs they were configured in.
+type Sink struct {
+	budgets []configs.Budget
+
+	mu    sync.Mutex
+	spend map[string]float64
+}
+
+func NewSink(budgets []configs.Budget) *Sink {
+	return &Sink{budgets: budgets, spend: map[string]float64{}}
+}
+
+func (s *Sink) Name() string {
+	return "budget"
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if aggregation != "Pod" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.budgets {
+		if b.Scope != "label" {
+			continue
+		}
+		key, value, _ := strings.Cut(b.Match, "=")
+		for _, a := range allocations {
+			labels, _ := a.Properties["labels"].(map[string]interface{})
+			if v, ok := labels[key].(string); ok && v == value {
+				s.spend[b.Name] += a.TotalCost
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return nil
+}
+
+// LabelSpend returns the cost of each label budget in the collected Window.
+func (s *Sink) LabelSpend() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spend := map[string]float64{}
+	for name, cost := range s.spend {
+		spend[name] = cost
+	}
+	return spend
+}
+
+// Alert is raised the first time in a month a budget reaches a threshold.
+type Alert struct {
+	Event     string    `json:"event"`
+	Budget    string    `json:"budget"`
+	Scope     string    `json:"scope"`
+	Match     string    `json:"match"`
+	Cluster   string    `json:"cluster"`
+	Month     string    `json:"month"`
+	Threshold float64   `json:"threshold"` // percent
+	Spend     float64   `json:"spend"`
+	Allowed   float64   `json:"allowed"`
+	Used      float64   `json:"used"` // percent
+	Window    string    `json:"window"`
+	RunID     string    `json:"runId"`
+	FiredAt   time.Time `json:"firedAt"`
+}
+
+// State is kept per cluster and month: the label spend of every Window, by budget and Window
+// start, and the alerts already fired.
+type State struct {
+	LabelSpend map[string]map[string]float64 `json:"labelSpend"`
+	Alerts     []Alert                       `json:"alerts"`
+}
+
+func stateKey(cluster, month string) string {
+	return configs.BudgetPrefix + cluster + "/State-" + month + ".json"
+}
+
+// Status is the month-to-date spend of one budget after a run.
+type Status struct {
+	configs.Budget
+	Month   string
+	Through string  // end of the last Window included
+	Days    float64 // days of the month up to Through
+	Spend   float64
+	Allowed float64 // Amount, or Amount × Days for daily budgets
+	Used    float64 // Spend / Allowed, in percent
+
+	MonthBudget float64 // Amount, or Amount × days in the month for daily budgets
+	Forecast    float64 // Spend extrapolated to the end of the month
+	Reached     float64 // highest threshold reached, 0 if none
+}
+
+// Evaluate computes the month-to-date status of every budget from the history of
+// cluster in the month, up to the end of window, and the alerts that were not fired yet this
+// month. The state of the month, including the label spend of this Window, is
+// saved before the alerts are returned, so a re-run does not raise them again.
+func Evaluate(store storage.Backend, budgets []configs.Budget, thresholds []float64, window, cluster, runID string, labelSpend map[string]float64) ([]Status, []Alert, error) {
+	if err := Validate(budgets); err != nil {
+		return nil, nil, err
+	}
+	start, end, ok := strings.Cut(window, ",")
+	windowStart, err := time.Parse(time.RFC3339, start)
+	if !ok || err != nil {
+		return nil, nil, fmt.Errorf("invalid window %q", window)
+	}
+	windowEnd, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid window %q", window)
+	}
+	monthStart := time.Date(windowStart.Year(), windowStart.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	if windowEnd.After(monthEnd) {
+		windowEnd = monthEnd
+	}
+	month := monthStart.Format("2006-01")
+	days := windowEnd.Sub(monthStart).Hours() / 24
+	daysInMonth := monthEnd.Sub(monthStart).Hours() / 24
+
+	key := stateKey(cluster, month)
+	state, version, err := readState(store, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, cost := range labelSpend {
+		if state.LabelSpend[name] == nil {
+			state.LabelSpend[name] = map[string]float64{}
+		}
+		state.LabelSpend[name][start] = cost
+	}
+
+	loaded := map[string][]allocation.Allocation{}
+	load := func(aggregation string) ([]allocation.Allocation, error) {
+		if allocations, ok := loaded[aggregation]; ok {
+			return allocations, nil
+		}
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		// The history of a shared store also holds the rows of other clusters.
+		var inMonth []allocation.Allocation
+		for _, a := range allocations {
+			t, err := time.Parse(time.RFC3339, a.WindowStart)
+			if err == nil && a.Cluster == cluster && !t.Before(monthStart) && t.Before(windowEnd) {
+				inMonth = append(inMonth, a)
+			}
+		}
+		loaded[aggregation] = inMonth
+		return inMonth, nil
+	}
+
+	fired := map[string]bool{}
+	for _, a := range state.Alerts {
+		fired[fmt.Sprintf("%s/%g", a.Budget, a.Threshold)] = true
+	}
+	sorted := append([]float64(nil), thresholds...)
+	sort.Float64s(sorted)
+
+	var statuses []Status
+	var alerts []Alert
+	for _, b := range budgets {
+		s := Status{Budget: b, Month: month, Through: windowEnd.Format(time.RFC3339), Days: days}
+		switch b.Scope {
+		case "namespace", "cluster":
+			aggregation := map[string]string{"namespace": "Namespace", "cluster": "Cluster"}[b.Scope]
+			allocations, err := load(aggregation)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, a := range allocations {
+				name := a.Namespace
+				if b.Scope == "cluster" {
+					name = a.Cluster
+				}
+				if ok, _ := path.Match(b.Match, name); ok {
+					s.Spend += a.TotalCost
+				}
+			}
+		case "label":
+			for _, cost := range state.LabelSpend[b.Name] {
+				s.Spend += cost
+			}
+		}
+
+		s.Allowed, s.MonthBudget = b.Amount, b.Amount
+		if b.Period == "daily" {
+			s.Allowed = b.Amount * days
+			s.MonthBudget = b.Amount * daysInMonth
+		}
+		if s.Allowed > 0 {
+			s.Used = s.Spend / s.Allowed * 100
+		}
+		if days > 0 {
+			s.Forecast = s.Spend / days * daysInMonth
+		}
+
+		for _, threshold := range sorted {
+			if s.Used < threshold {
+				break
+			}
+			s.Reached = threshold
+			id := fmt.Sprintf("%s/%g", b.Name, threshold)
+			if fired[id] {
+				continue
+			}
+			fired[id] = true
+			alert := Alert{
+				Event: webhook.EventBudgetAlert, Budget: b.Name, Scope: b.Scope, Match: b.Match, Cluster: cluster,
+				Month: month, Threshold: threshold, Spend: s.Spend, Allowed: s.Allowed, Used: s.Used,
+				Window: window, RunID: runID, FiredAt: time.Now().UTC(),
+			}
+			alerts = append(alerts, alert)
+			state.Alerts = append(state.Alerts, alert)
+		}
+		statuses = append(statuses, s)
+	}
+
+	if err := writeState(store, key, state, version); err != nil {
+		return nil, nil, err
+	}
+	return statuses, alerts, nil
+}
+
+func readState(store storage.Backend, key string) (State, string, error) {
+	state := State{LabelSpend: map[string]map[string]float64{}}
+	object, err := store.Read(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return state, "", nil
+	}
+	if err != nil {
+		return state, "", err
+	}
+	if err := json.Unmarshal(object.Data, &state); err != nil {
+		return state, "", fmt.Errorf("%s: %w", key, err)
+	}
+	if state.LabelSpend == nil {
+		state.LabelSpend = map[string]map[string]float64{}
+	}
+	return state, object.Version, nil
+}
+
+// writeState saves the state only if it was not changed since it was read, so two
+// runs evaluating the same month at once cannot both fire an alert.
+func writeState(store storage.Backend, key string, state State, version string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteIf(key, data, "application/json", version)
+}
+
+// Header is the header of the budget status CSV.
+var Header = []string{
+	"Budget", "Scope", "Match", "Period", "Amount", "Month", "Through", "Days",
+	"Spend", "Allowed", "Used", "Threshold Reached", "Month Budget", "Forecast",
+}
+
+// Records returns the statuses as CSV records, without the header.
+func Records(statuses []Status) [][]string {
+	records := make([][]string, 0, len(statuses))
+	for _, s := range statuses {
+		reached := ""
+		if s.Reached > 0 {
+			reached = fmt.Sprintf("%g", s.Reached)
+		}
+		records = append(records, []string{
+			s.Name, s.Scope, s.Match, s.Period, fmt.Sprintf("%f", s.Amount), s.Month, s.Through, fmt.Sprintf("%g", s.Days),
+			fmt.Sprintf("%f", s.Spend), fmt.Sprintf("%f", s.Allowed), fmt.Sprintf("%f", s.Used), reached,
+			fmt.Sprintf("%f", s.MonthBudget), fmt.Sprintf("%f", s.Forecast),
+		})
+	}
+	return records
+}
@@ -0,0 +1,173 @@
+package budget
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"math"
+	"testing"
+)
+
+// namespaceRow is a Namespace history row of one day in October 2024.
+func namespaceRow(name, cluster string, day int, cost float64) []string {
+	start := fmt.Sprintf("2024-10-%02dT00:00:00Z", day)
+	end := fmt.Sprintf("2024-10-%02dT00:00:00Z", day+1)
+	c := fmt.Sprintf("%f", cost)
+	return []string{name, cluster, "eu-west-1", start, end, c, "0", "0", "0", "0", "0", c, "50", "50", "50", "0", "0"}
+}
+
+func newStore(t *testing.T, rows ...[]string) storage.Backend {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.Append(store, "Namespace/Namespace.csv", schema.Namespace, rows); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestEvaluate(t *testing.T) {
+	const window = "2024-10-02T00:00:00Z,2024-10-03T00:00:00Z"
+	rows := [][]string{
+		namespaceRow("payments", "prod", 1, 30),
+		namespaceRow("payments", "prod", 2, 30),
+		namespaceRow("payments", "staging", 2, 1000), // another cluster in the same store
+		namespaceRow("payments", "prod", 3, 500),     // after the Window
+		namespaceRow("search", "prod", 2, 70),
+	}
+	tests := []struct {
+		name       string
+		budget     configs.Budget
+		labelSpend map[string]float64
+		spend      float64
+		allowed    float64
+		reached    float64
+		alerts     []float64
+	}{
+		{
+			name:    "monthly namespace",
+			budget:  configs.Budget{Name: "payments", Scope: "namespace", Match: "payments", Period: "monthly", Amount: 100},
+			spend:   60,
+			allowed: 100,
+			reached: 50,
+			alerts:  []float64{50},
+		},
+		{
+			name:    "daily namespace pattern",
+			budget:  configs.Budget{Name: "all", Scope: "namespace", Match: "*", Period: "daily", Amount: 50},
+			spend:   130,
+			allowed: 100,
+			reached: 100,
+			alerts:  []float64{50, 80, 100},
+		},
+		{
+			name:       "label",
+			budget:     configs.Budget{Name: "checkout", Scope: "label", Match: "app=checkout", Period: "monthly", Amount: 10},
+			labelSpend: map[string]float64{"checkout": 4},
+			spend:      4,
+			allowed:    10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(t, rows...)
+			budgets := []configs.Budget{tt.budget}
+			statuses, alerts, err := Evaluate(store, budgets, []float64{50, 80, 100}, window, "prod", "run-1", tt.labelSpend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(statuses) != 1 {
+				t.Fatalf("got %d statuses, want 1", len(statuses))
+			}
+			s := statuses[0]
+			if math.Abs(s.Spend-tt.spend) > 1e-9 || s.Allowed != tt.allowed || s.Reached != tt.reached {
+				t.Errorf("spend %g, allowed %g, reached %g; want %g, %g, %g", s.Spend, s.Allowed, s.Reached, tt.spend, tt.allowed, tt.reached)
+			}
+			if len(alerts) != len(tt.alerts) {
+				t.Fatalf("got %d alerts, want %v", len(alerts), tt.alerts)
+			}
+			for i, a := range alerts {
+				if a.Threshold != tt.alerts[i] || a.Cluster != "prod" {
+					t.Errorf("alert %d: threshold %g in %s, want %g in prod", i, a.Threshold, a.Cluster, tt.alerts[i])
+				}
+			}
+
+			// A re-run of the same Window does not fire the alerts again.
+			_, alerts, err = Evaluate(store, budgets, []float64{50, 80, 100}, window, "prod", "run-2", tt.labelSpend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alerts) != 0 {
+				t.Errorf("re-run fired %d alerts", len(alerts))
+			}
+		})
+	}
+}
+
+func TestEvaluateKeepsStatePerCluster(t *testing.T) {
+	const window = "2024-10-02T00:00:00Z,2024-10-03T00:00:00Z"
+	store := newStore(t, namespaceRow("payments", "prod", 2, 60), namespaceRow("payments", "staging", 2, 60))
+	budgets := []configs.Budget{{Name: "payments", Scope: "namespace", Match: "payments", Period: "monthly", Amount: 100}}
+
+	for _, cluster := range []string{"prod", "staging"} {
+		_, alerts, err := Evaluate(store, budgets, []float64{50}, window, cluster, "run", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 1 {
+			t.Errorf("%s: got %d alerts, want 1", cluster, len(alerts))
+		}
+	}
+	for _, key := range []string{"Budgets/prod/State-2024-10.json", "Budgets/staging/State-2024-10.json"} {
+		if _, err := store.Read(key); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+}
+
+func TestEvaluateSumsLabelSpendOfTheMonth(t *testing.T) {
+	store := newStore(t)
+	budgets := []configs.Budget{{Name: "checkout", Scope: "label", Match: "app=checkout", Period: "monthly", Amount: 10}}
+	spend := map[string]float64{"checkout": 3}
+
+	for _, window := range []string{
+		"2024-10-01T00:00:00Z,2024-10-02T00:00:00Z",
+		"2024-10-02T00:00:00Z,2024-10-03T00:00:00Z",
+		"2024-10-02T00:00:00Z,2024-10-03T00:00:00Z", // re-run, replaces the spend of the day
+	} {
+		if _, _, err := Evaluate(store, budgets, nil, window, "prod", "run", spend); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses, _, err := Evaluate(store, budgets, nil, "2024-10-02T00:00:00Z,2024-10-03T00:00:00Z", "prod", "run", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].Spend != 6 {
+		t.Errorf("spend %g, want 6", statuses[0].Spend)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		budgets []configs.Budget
+		ok      bool
+	}{
+		{"valid", []configs.Budget{{Name: "a", Scope: "cluster", Match: "*", Period: "daily", Amount: 1}}, true},
+		{"duplicate name", []configs.Budget{{Name: "a", Scope: "cluster", Match: "*", Period: "daily", Amount: 1}, {Name: "a", Scope: "cluster", Match: "*", Period: "daily", Amount: 1}}, false},
+		{"label without value", []configs.Budget{{Name: "a", Scope: "label", Match: "app", Period: "daily", Amount: 1}}, false},
+		{"unknown scope", []configs.Budget{{Name: "a", Scope: "node", Match: "*", Period: "daily", Amount: 1}}, false},
+		{"unknown period", []configs.Budget{{Name: "a", Scope: "cluster", Match: "*", Period: "weekly", Amount: 1}}, false},
+		{"no amount", []configs.Budget{{Name: "a", Scope: "cluster", Match: "*", Period: "daily"}}, false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.budgets); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+}
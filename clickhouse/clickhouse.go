@@ -0,0 +1,185 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeFormat is the DateTime text format ClickHouse parses without extra settings.
+const timeFormat = "2006-01-02 15:04:05"
+
+// Sink inserts allocations into one ClickHouse table per aggregation through the
+// HTTP interface. Tables use ReplacingMergeTree ordered by name, cluster, namespace
+// and window, so rows of a re-run window replace the earlier ones when parts are merged.
+type Sink struct {
+	endpoint     string
+	database     string
+	user         string
+	password     string
+	aggregations map[string]bool
+	client       *http.Client
+
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+// Open checks that the server at endpoint answers and returns a sink writing the
+// given aggregations into database. An empty aggregations list writes all of them.
+func Open(endpoint, database, user, password string, aggregations []string) (*Sink, error) {
+	s := &Sink{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		database:     database,
+		user:         user,
+		password:     password,
+		aggregations: map[string]bool{},
+		client:       &http.Client{Timeout: 30 * time.Second},
+		tables:       map[string]bool{},
+	}
+	for _, aggregation := range aggregations {
+		s.aggregations[aggregation] = true
+	}
+	if err := s.exec("SELECT 1", nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "clickhouse"
+}
+
+func quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "\\`") + "`"
+}
+
+func (s *Sink) table(aggregation string) string {
+	return quote(s.database) + "." + quote(schema.ColumnName(aggregation))
+}
+
+func columnType(kind allocation.Kind) string {
+	switch kind {
+	case allocation.Float:
+		return "Float64"
+	case allocation.Time:
+		return "DateTime('UTC')"
+	}
+	return "String"
+}
+
+// exec sends query to the HTTP interface, with body as the data of an INSERT.
+func (s *Sink) exec(query string, body []byte) error {
+	u := s.endpoint + "/?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if s.user != "" {
+		req.Header.Set("X-ClickHouse-User", s.user)
+		req.Header.Set("X-ClickHouse-Key", s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("clickhouse returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// migrate creates the database and the table of an aggregation and adds any column
+// it is missing, so tables created by older versions pick up new fields.
+func (s *Sink) migrate(aggregation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[aggregation] {
+		return nil
+	}
+
+	table := s.table(aggregation)
+	var keys, columns []string
+	for _, f := range allocation.Fields {
+		columns = append(columns, fmt.Sprintf("%s %s", quote(f.Name), columnType(f.Kind)))
+	}
+	for _, f := range allocation.Fields[:allocation.KeyFields] {
+		keys = append(keys, quote(f.Name))
+	}
+	columns = append(columns, "run_id String", "updated_at DateTime('UTC')")
+
+	statements := []string{
+		fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quote(s.database)),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree(updated_at) ORDER BY (%s)",
+			table, strings.Join(columns, ", "), strings.Join(keys, ", ")),
+	}
+	for _, f := range allocation.Fields[allocation.KeyFields:] {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, quote(f.Name), columnType(f.Kind)))
+	}
+
+	for _, statement := range statements {
+		if err := s.exec(statement, nil); err != nil {
+			return err
+		}
+	}
+	s.tables[aggregation] = true
+	return nil
+}
+
+// rows encodes allocations as JSONEachRow, one object per line.
+func rows(allocations []allocation.Allocation, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, a := range allocations {
+		row := map[string]interface{}{}
+		for _, f := range allocation.Fields {
+			value := f.Value(a)
+			if t, ok := value.(time.Time); ok {
+				value = t.UTC().Format(timeFormat)
+			}
+			row[f.Name] = value
+		}
+		row["run_id"] = configs.RunID
+		row["updated_at"] = now.UTC().Format(timeFormat)
+		if err := encoder.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if len(s.aggregations) > 0 && !s.aggregations[aggregation] {
+		return nil
+	}
+	if len(allocations) == 0 {
+		return nil
+	}
+	if err := s.migrate(aggregation); err != nil {
+		return err
+	}
+
+	body, err := rows(allocations, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.exec(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", s.table(aggregation)), body)
+}
+
+func (s *Sink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
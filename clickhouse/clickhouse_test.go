@@ -0,0 +1,133 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// server records the queries it gets, with the body of each INSERT, and fails
+// the queries containing fail.
+type server struct {
+	mu      sync.Mutex
+	fail    string
+	queries []string
+	inserts [][]byte
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("X-ClickHouse-User") != "user" || r.Header.Get("X-ClickHouse-Key") != "password" {
+		http.Error(w, "Code: 516. Authentication failed", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query().Get("query")
+	s.queries = append(s.queries, query)
+	if s.fail != "" && strings.Contains(query, s.fail) {
+		http.Error(w, "Code: 62. Syntax error", http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(query, "INSERT") {
+		body, _ := io.ReadAll(r.Body)
+		s.inserts = append(s.inserts, body)
+	}
+}
+
+func open(t *testing.T, s *server, aggregations ...string) *Sink {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	sink, err := Open(ts.URL+"/", "kubecost", "user", "password", aggregations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func TestWrite(t *testing.T) {
+	s := &server{}
+	sink := open(t, s)
+	pods := []allocation.Allocation{{
+		Aggregation: "Pod",
+		Name:        "api-0",
+		Cluster:     "prod",
+		Namespace:   "payments",
+		WindowStart: "2024-10-01T00:00:00Z",
+		WindowEnd:   "2024-10-02T00:00:00Z",
+		TotalCost:   1.5,
+	}}
+	for i := 0; i < 2; i++ {
+		if err := sink.Write("Pod", pods); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The table is created once, then every Write inserts.
+	var creates int
+	for _, query := range s.queries {
+		if strings.HasPrefix(query, "CREATE TABLE") {
+			creates++
+			if !strings.Contains(query, "`kubecost`.`pod`") || !strings.Contains(query, "ReplacingMergeTree(updated_at)") {
+				t.Errorf("create %s", query)
+			}
+			if !strings.Contains(query, "ORDER BY (`name`, `cluster`, `namespace`") {
+				t.Errorf("sorting key of %s", query)
+			}
+		}
+	}
+	if creates != 1 || len(s.inserts) != 2 {
+		t.Fatalf("got %d creates and %d inserts, want 1 and 2", creates, len(s.inserts))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(s.inserts[0]))
+	var rows []map[string]interface{}
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	if rows[0]["name"] != "api-0" || rows[0]["total_cost"] != 1.5 || rows[0]["window_start"] != "2024-10-01 00:00:00" {
+		t.Errorf("row %v", rows[0])
+	}
+}
+
+func TestWriteSkipsAggregations(t *testing.T) {
+	s := &server{}
+	sink := open(t, s, "Namespace")
+	if err := sink.Write("Pod", []allocation.Allocation{{Name: "api-0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.queries) != 1 {
+		t.Errorf("got queries %q, want only the check of Open", s.queries)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	s := &server{fail: "INSERT"}
+	sink := open(t, s)
+	err := sink.Write("Pod", []allocation.Allocation{{Name: "api-0"}})
+	if err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Errorf("got %v, want the server message", err)
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	ts := httptest.NewServer(&server{})
+	defer ts.Close()
+	if _, err := Open(ts.URL, "kubecost", "user", "wrong", nil); err == nil {
+		t.Error("opened with a rejected password")
+	}
+}
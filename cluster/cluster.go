@@ -1,22 +1,22 @@
 package cluster
 
 import (
-	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"kubecost-efficiency-fetcher/allocation"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"os"
 )
 
-func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region string, wg *sync.WaitGroup) {
+func FetchAndWriteClusterData(inputURL,clusterName, window, region string, store storage.Backend, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 	u, err := url.Parse(inputURL)
@@ -68,55 +68,11 @@ func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region s
 	data := result["data"].([]interface{})
 
 
-	svc := configs.Svc
+	objectKey := configs.ObjectKey("Cluster")
 
 
-	objectKey := "Cluster/Cluster.csv"
-
-
-	existingData := [][]string{}
-	fileExists := false
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err == nil {
-
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		reader := csv.NewReader(resp.Body)
-		existingData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing CSV data:", err)
-			return
-		}
-		fileExists = true
-	} else {
-
-		configs.InfoLogger.Println("No existing Cluster.csv file found. A new one will be created.")
-	}
-
-
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
-	defer writer.Flush()
-
-
-	if !fileExists {
-		header := []string{"Cluster", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency"}
-		if err := writer.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to CSV:", err)
-			return
-		}
-	}
+	records := [][]string{}
+	allocations := []allocation.Allocation{}
 
 	for _, element := range data {
 		if element == nil{
@@ -128,6 +84,8 @@ func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region s
 		for _, clusterData := range clusterMap {
 			clusterOne := clusterData.(map[string]interface{})
 
+			properties, _ := clusterOne["properties"].(map[string]interface{})
+
 			cluster := clusterOne["name"].(string)
 			if cluster == "cluster-one" {
 				cluster = clusterName
@@ -150,6 +108,8 @@ func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region s
 			cpuEfficiency := clusterOne["cpuEfficiency"].(float64) * 100
 			ramEfficiency := clusterOne["ramEfficiency"].(float64) * 100
 			totalEfficiency := clusterOne["totalEfficiency"].(float64) * 100
+			cpuWaste := allocation.Waste(cpuCost, cpuEfficiency)
+			ramWaste := allocation.Waste(ramCost, ramEfficiency)
 
 			record := []string{
 				cluster, windowStart, windowEnd,
@@ -159,24 +119,42 @@ func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region s
 				fmt.Sprintf("%f", totalCost),
 				fmt.Sprintf("%f", cpuEfficiency), fmt.Sprintf("%f", ramEfficiency),
 				fmt.Sprintf("%f", totalEfficiency),
+				fmt.Sprintf("%f", cpuWaste), fmt.Sprintf("%f", ramWaste),
+			}
+			records = append(records, record)
+
+			alloc := allocation.Allocation{
+				Aggregation:      "Cluster",
+				Name:             cluster,
+				Cluster:          clusterName,
+				WindowStart:      windowStart,
+				WindowEnd:        windowEnd,
+				CpuCost:          cpuCost,
+				GpuCost:          gpuCost,
+				RamCost:          ramCost,
+				PVCost:           pvCost,
+				NetworkCost:      networkCost,
+				LoadBalancerCost: loadBalancerCost,
+				TotalCost:        totalCost,
+				CpuEfficiency:    cpuEfficiency,
+				RamEfficiency:    ramEfficiency,
+				TotalEfficiency:  totalEfficiency,
+				CpuWaste:         cpuWaste,
+				RamWaste:         ramWaste,
+				Properties:       properties,
 			}
-			existingData = append(existingData, record)
+			allocations = append(allocations, alloc)
 		}
 	}
 
 
-	if err := writer.WriteAll(existingData); err != nil {
-		configs.ErrorLogger.Println("Error writing data to CSV:", err)
-		return
-	}
-
-	err = os.MkdirAll("Output", 0755)
+	content, err := history.Append(store, objectKey, schema.Cluster, records)
 	if err != nil {
-		configs.ErrorLogger.Println("Error creating directory:", err)
+		configs.ErrorLogger.Println("Error uploading updated CSV to storage:", err)
 		return
 	}
 
-	err = os.WriteFile("Output/Cluster.csv", buffer.Bytes(), 0644)
+	err = storage.WriteLocalCopy("Cluster.csv", content)
 	if err != nil {
 		configs.ErrorLogger.Println("Error saving file cluster.csv:", err)
 		return
@@ -185,16 +163,7 @@ func FetchAndWriteClusterData(inputURL,clusterName, window, bucketName, region s
 	
 
 
-	_, err = svc.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectKey),
-		Body:        bytes.NewReader(buffer.Bytes()),
-		ContentType: aws.String("text/csv"),
-	})
-	if err != nil {
-		configs.ErrorLogger.Println("Error uploading updated CSV to S3:", err)
-		return
-	}
+	sink.Publish("Cluster", allocations)
 
-	configs.InfoLogger.Println("Cluster data successfully written to S3")
+	configs.InfoLogger.Println("Cluster data successfully written to", store)
 }
\ No newline at end of file
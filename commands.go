@@ -0,0 +1,609 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/anomaly"
+	"kubecost-efficiency-fetcher/budget"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/dashboard"
+	"kubecost-efficiency-fetcher/digest"
+	"kubecost-efficiency-fetcher/email"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/manifest"
+	"kubecost-efficiency-fetcher/markdown"
+	"kubecost-efficiency-fetcher/metrics"
+	"kubecost-efficiency-fetcher/report"
+	"kubecost-efficiency-fetcher/rightsizing"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
+	"kubecost-efficiency-fetcher/wasters"
+	"kubecost-efficiency-fetcher/webhook"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// migrate reports, and with -apply rewrites, objects whose header differs from the current schema.
+func migrate(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "rewrite the objects to the current schema")
+	flags.Parse(args)
+
+	count, err := history.MigrateObjects(store, *apply)
+	if err != nil {
+		configs.ErrorLogger.Println("Error migrating history:", err)
+		os.Exit(1)
+	}
+	switch {
+	case count == 0:
+		configs.InfoLogger.Println("All objects use the current schema")
+	case !*apply:
+		configs.InfoLogger.Printf("%d objects need a migration. Run again with -apply to rewrite them.\n", count)
+	default:
+		configs.InfoLogger.Printf("%d objects migrated\n", count)
+	}
+}
+
+// verify checks the objects of a run against its manifest and exits non-zero on any mismatch.
+func verify(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	runID := flags.String("run", "", "run ID of the manifest to verify (default: latest run)")
+	flags.Parse(args)
+
+	m, err := manifest.Load(store, configs.ClusterName, *runID)
+	if err != nil {
+		configs.ErrorLogger.Println("Error loading manifest:", err)
+		os.Exit(1)
+	}
+
+	problems, err := manifest.Verify(store, m)
+	if err != nil {
+		configs.ErrorLogger.Println("Error verifying objects:", err)
+		os.Exit(1)
+	}
+	for _, p := range problems {
+		configs.ErrorLogger.Printf("%s: %s\n", p.Key, p.Reason)
+	}
+	if !m.Complete {
+		configs.ErrorLogger.Printf("Run %s is incomplete, no data was written for %v\n", m.RunID, m.Missing)
+	}
+	if len(problems) > 0 || !m.Complete {
+		os.Exit(1)
+	}
+	configs.InfoLogger.Printf("Run %s verified: %d objects match the manifest\n", m.RunID, len(m.Objects))
+}
+
+// serve collects the current Window, then again whenever the day changes, and
+// serves the latest values on /metrics. A Window the latest run of the cluster
+// already collected is served from the history rather than collected again, so a
+// restart does not append it to the history and republish it to the sinks twice.
+func serve(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", configs.MetricsListenAddress, "address to serve /metrics on")
+	flags.Parse(args)
+
+	exporter := metrics.NewExporter(configs.MetricsCardinalityLimits, configs.MetricsDefaultCardinalityLimit)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	go func() {
+		m, err := manifest.Load(store, configs.ClusterName, "")
+		collected := err == nil && m.Window == configs.Window
+		for {
+			if collected {
+				configs.InfoLogger.Printf("Window %s was collected by run %s, serving it from the history\n", m.Window, m.RunID)
+				if err := loadExporter(store, exporter, m.Window); err != nil {
+					configs.ErrorLogger.Println("Error reading history:", err)
+				}
+			} else {
+				sink.Add(exporter)
+				collect(store)
+			}
+			collected = false
+
+			for configs.Window == configs.YesterdayWindow(time.Now()) {
+				time.Sleep(configs.MetricsRefreshInterval)
+			}
+			configs.Window = configs.YesterdayWindow(time.Now())
+			configs.RunID = time.Now().UTC().Format("20060102T150405Z")
+		}
+	}()
+
+	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	configs.InfoLogger.Println("Serving metrics on", *listen+"/metrics")
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		configs.ErrorLogger.Println("Error serving metrics:", err)
+		os.Exit(1)
+	}
+}
+
+// loadExporter fills the exporter with the rows of window and of the configured
+// cluster from the stored history of every aggregation.
+func loadExporter(store storage.Backend, exporter *metrics.Exporter, window string) error {
+	start, _, _ := strings.Cut(window, ",")
+	for _, aggregation := range schema.Aggregations {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return err
+		}
+		var rows []allocation.Allocation
+		for _, a := range allocations {
+			if a.WindowStart == start && a.Cluster == configs.ClusterName {
+				rows = append(rows, a)
+			}
+		}
+		if err := exporter.Write(aggregation, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendDigests posts the digest of the cluster to DigestChannels and the digest of
+// each routed namespace to its channels. With dryRun, the messages are printed instead.
+func sendDigests(store storage.Backend, dryRun bool) error {
+	templates := digest.Templates{Title: configs.DigestTitleTemplate, Summary: configs.DigestSummaryTemplate}
+
+	routes := map[string][]configs.DigestChannel{"": configs.DigestChannels}
+	for namespace, channels := range configs.DigestNamespaceRoutes {
+		routes[namespace] = channels
+	}
+
+	var errs []error
+	for namespace, channels := range routes {
+		if len(channels) == 0 {
+			continue
+		}
+		d, err := digest.Build(store, configs.ClusterName, configs.Window, configs.RunID, namespace, configs.DigestTopN)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			errs = append(errs, digest.Send(d, channels, templates, configs.DigestCurrency))
+			continue
+		}
+		for _, channel := range channels {
+			body, err := digest.Render(d, channel.Format, templates, configs.DigestCurrency)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s %s\n%s\n", channel.Format, channel.URL, body)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendDigest posts, or with -dry-run prints, the digest of the configured Window.
+func sendDigest(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the messages instead of posting them")
+	flags.Parse(args)
+
+	if err := sendDigests(store, *dryRun); err != nil {
+		configs.ErrorLogger.Println("Error sending digest:", err)
+		os.Exit(1)
+	}
+}
+
+// sendEmail renders the email report of the last -days days and sends it, or with
+// -dry-run writes the messages to OutputDir/Email.
+func sendEmail(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("email", flag.ExitOnError)
+	days := flags.Int("days", configs.EmailReportDays, "days covered by the report, ending with the configured Window")
+	dryRun := flags.Bool("dry-run", false, "write the messages to files instead of sending them")
+	flags.Parse(args)
+
+	_, windowEnd, _ := strings.Cut(configs.Window, ",")
+	end, err := time.Parse(time.RFC3339, windowEnd)
+	if err != nil {
+		configs.ErrorLogger.Println("Error parsing Window:", err)
+		os.Exit(1)
+	}
+	summary, err := report.Build(store, configs.ClusterName, end.AddDate(0, 0, -*days), end)
+	if err != nil {
+		configs.ErrorLogger.Println("Error reading history:", err)
+		os.Exit(1)
+	}
+
+	server := email.SMTP{
+		Host:     configs.SMTPHost,
+		Port:     configs.SMTPPort,
+		Username: configs.SMTPUsername,
+		Password: configs.SMTPPassword,
+		StartTLS: configs.SMTPStartTLS,
+		From:     configs.EmailFrom,
+	}
+	labels, err := email.LoadLabels(store, configs.EmailLabelsKey)
+	if err != nil {
+		configs.ErrorLogger.Println("Error reading email labels:", err)
+		os.Exit(1)
+	}
+	failed := false
+	for to, r := range email.Plan(summary, configs.EmailRecipients, configs.EmailNamespaceRecipients, configs.EmailLabelRecipients, labels, configs.EmailTopN) {
+		m, err := email.Render(to, r, configs.EmailCurrency)
+		if err != nil {
+			configs.ErrorLogger.Println("Error rendering email report:", err)
+			os.Exit(1)
+		}
+
+		if *dryRun {
+			data, err := m.Bytes(configs.EmailFrom)
+			if err == nil {
+				err = os.MkdirAll(filepath.Join(configs.OutputDir, "Email"), 0755)
+			}
+			if err == nil {
+				err = os.WriteFile(filepath.Join(configs.OutputDir, "Email", to+".eml"), data, 0644)
+			}
+			if err != nil {
+				configs.ErrorLogger.Println("Error writing email report:", err)
+				os.Exit(1)
+			}
+			configs.InfoLogger.Printf("Email report for %s written to %s\n", to, filepath.Join(configs.OutputDir, "Email", to+".eml"))
+			continue
+		}
+
+		if err := server.Send(m); err != nil {
+			configs.ErrorLogger.Printf("Error sending email report to %s: %v\n", to, err)
+			failed = true
+			continue
+		}
+		configs.InfoLogger.Println("Email report sent to", to)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// writeDashboard renders the HTML dashboard of the last -days days to OutputDir and,
+// with -publish, stores it at DashboardKey.
+func writeDashboard(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	days := flags.Int("days", configs.DashboardDays, "days covered by the dashboard, ending with the configured Window; 0 for all history")
+	publish := flags.Bool("publish", false, "also store the page at "+configs.DashboardKey)
+	flags.Parse(args)
+
+	_, windowEnd, _ := strings.Cut(configs.Window, ",")
+	end, err := time.Parse(time.RFC3339, windowEnd)
+	if err != nil {
+		configs.ErrorLogger.Println("Error parsing Window:", err)
+		os.Exit(1)
+	}
+	var start time.Time
+	if *days > 0 {
+		start = end.AddDate(0, 0, -*days)
+	}
+
+	d, err := dashboard.Build(store, start, end, configs.DashboardTopN)
+	if err != nil {
+		configs.ErrorLogger.Println("Error reading history:", err)
+		os.Exit(1)
+	}
+	page, err := dashboard.Render(d, configs.DashboardTitle, configs.DashboardCurrency)
+	if err != nil {
+		configs.ErrorLogger.Println("Error rendering dashboard:", err)
+		os.Exit(1)
+	}
+
+	path := filepath.Join(configs.OutputDir, "dashboard.html")
+	err = os.MkdirAll(configs.OutputDir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, page, 0644)
+	}
+	if err != nil {
+		configs.ErrorLogger.Println("Error writing dashboard:", err)
+		os.Exit(1)
+	}
+	configs.InfoLogger.Println("Dashboard written to", path)
+
+	if *publish {
+		if err := store.Write(configs.DashboardKey, page, "text/html; charset=utf-8"); err != nil {
+			configs.ErrorLogger.Println("Error publishing dashboard:", err)
+			os.Exit(1)
+		}
+		configs.InfoLogger.Printf("Dashboard published to %s/%s\n", store, configs.DashboardKey)
+	}
+}
+
+// writeMarkdown prints the Markdown report of -window, compared with -compare when set.
+func writeMarkdown(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("markdown", flag.ExitOnError)
+	window := flags.String("window", configs.Window, "window of the report, <start>,<end>")
+	compare := flags.String("compare", "", `window to compare with, <start>,<end>, or "previous" for the period of the same length before -window`)
+	out := flags.String("out", "", "write the report to this file instead of stdout")
+	flags.Parse(args)
+
+	current, err := markdown.ParsePeriod(*window)
+	if err != nil {
+		configs.ErrorLogger.Println("Error parsing -window:", err)
+		os.Exit(1)
+	}
+	periods := []markdown.Period{current}
+	if *compare == "previous" {
+		periods = append(periods, current.Previous())
+	} else if *compare != "" {
+		previous, err := markdown.ParsePeriod(*compare)
+		if err != nil {
+			configs.ErrorLogger.Println("Error parsing -compare:", err)
+			os.Exit(1)
+		}
+		periods = append(periods, previous)
+	}
+
+	windows, err := markdown.Load(store, configs.ClusterName, configs.MarkdownAggregations, periods...)
+	if err != nil {
+		configs.ErrorLogger.Println("Error reading history:", err)
+		os.Exit(1)
+	}
+	var previous *markdown.Window
+	if len(windows) > 1 {
+		previous = windows[1]
+	}
+	text, err := markdown.Render(windows[0], previous, markdown.Options{
+		Title:        configs.MarkdownTitle,
+		Cluster:      configs.ClusterName,
+		Sections:     configs.MarkdownSections,
+		Aggregations: configs.MarkdownAggregations,
+		TopN:         configs.MarkdownTopN,
+		Currency:     configs.MarkdownCurrency,
+	})
+	if err != nil {
+		configs.ErrorLogger.Println("Error rendering Markdown report:", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		fmt.Print(text)
+		return
+	}
+	if err := os.WriteFile(*out, []byte(text), 0644); err != nil {
+		configs.ErrorLogger.Println("Error writing Markdown report:", err)
+		os.Exit(1)
+	}
+	configs.InfoLogger.Println("Markdown report written to", *out)
+}
+
+// rightsize writes the rightsizing recommendations of the last -days days to the
+// store and to OutputDir.
+func rightsize(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("rightsize", flag.ExitOnError)
+	days := flags.Int("days", configs.RightsizingDays, "days of history used, ending with the configured Window")
+	flags.Parse(args)
+
+	_, windowEnd, _ := strings.Cut(configs.Window, ",")
+	end, err := time.Parse(time.RFC3339, windowEnd)
+	if err != nil {
+		configs.ErrorLogger.Println("Error parsing Window:", err)
+		os.Exit(1)
+	}
+	options := rightsizing.Options{
+		Percentile:        configs.RightsizingPercentile,
+		TargetUtilization: configs.RightsizingTargetUtilization,
+		Headroom:          configs.RightsizingHeadroom,
+		MinCPUCores:       configs.RightsizingMinCPUCores,
+		MinRAMBytes:       configs.RightsizingMinRAMBytes,
+	}
+
+	rows := [][]string{rightsizing.Header}
+	for _, aggregation := range configs.RightsizingAggregations {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			configs.ErrorLogger.Println("Error reading history:", err)
+			os.Exit(1)
+		}
+		recommendations := rightsizing.Recommend(report.InPeriod(allocations, end.AddDate(0, 0, -*days), end), options)
+		rows = append(rows, rightsizing.Records(recommendations)...)
+	}
+	content, err := history.Encode(rows)
+	if err != nil {
+		configs.ErrorLogger.Println("Error encoding recommendations:", err)
+		os.Exit(1)
+	}
+
+	day := strings.SplitN(configs.Window, "T", 2)[0]
+	key := configs.RightsizingPrefix + day + ".csv"
+	if err := store.Write(key, content, "text/csv"); err != nil {
+		configs.ErrorLogger.Println("Error storing recommendations:", err)
+		os.Exit(1)
+	}
+	path := filepath.Join(configs.OutputDir, "Rightsizing.csv")
+	err = os.MkdirAll(configs.OutputDir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		configs.ErrorLogger.Println("Error writing recommendations:", err)
+		os.Exit(1)
+	}
+	configs.InfoLogger.Printf("%d rightsizing recommendations written to %s/%s and %s\n", len(rows)-1, store, key, path)
+}
+
+// rankWasters writes the top wasters of the last -days days to the store and to
+// OutputDir, and prints them.
+func rankWasters(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("wasters", flag.ExitOnError)
+	days := flags.Int("days", configs.WastersDays, "days ranked, ending with the configured Window")
+	minCost := flags.Float64("min-cost", configs.WastersMinCost, "skip workloads costing less over the period")
+	namespaces := flags.String("namespace", strings.Join(configs.WastersNamespaces, ","), "comma separated namespaces or patterns to keep; empty keeps all")
+	flags.Parse(args)
+
+	_, windowEnd, _ := strings.Cut(configs.Window, ",")
+	end, err := time.Parse(time.RFC3339, windowEnd)
+	if err != nil {
+		configs.ErrorLogger.Println("Error parsing Window:", err)
+		os.Exit(1)
+	}
+	filter := wasters.Filter{MinCost: *minCost, ExcludeNamespaces: configs.WastersExcludeNamespaces}
+	if *namespaces != "" {
+		filter.Namespaces = strings.Split(*namespaces, ",")
+	}
+
+	rows := [][]string{wasters.Header}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "Aggregation\tNamespace\tName\tCost\tCpu Waste\tRam Waste\tWaste\t")
+	for _, aggregation := range configs.WastersAggregations {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			configs.ErrorLogger.Println("Error reading history:", err)
+			os.Exit(1)
+		}
+		ranked := wasters.Rank(report.InPeriod(allocations, end.AddDate(0, 0, -*days), end), filter, configs.WastersTopN)
+		rows = append(rows, wasters.Records(ranked)...)
+		for _, w := range ranked {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t\n", w.Aggregation, w.Namespace, w.Name, w.TotalCost, w.CpuWaste, w.RamWaste, w.Waste())
+		}
+	}
+	out.Flush()
+
+	content, err := history.Encode(rows)
+	if err != nil {
+		configs.ErrorLogger.Println("Error encoding wasters:", err)
+		os.Exit(1)
+	}
+	day := strings.SplitN(configs.Window, "T", 2)[0]
+	key := configs.WastersPrefix + day + ".csv"
+	if err := store.Write(key, content, "text/csv"); err != nil {
+		configs.ErrorLogger.Println("Error storing wasters:", err)
+		os.Exit(1)
+	}
+	path := filepath.Join(configs.OutputDir, "Wasters.csv")
+	err = os.MkdirAll(configs.OutputDir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		configs.ErrorLogger.Println("Error writing wasters:", err)
+		os.Exit(1)
+	}
+	configs.InfoLogger.Printf("%d wasters written to %s/%s and %s\n", len(rows)-1, store, key, path)
+}
+
+// detectAnomalies compares the Window with the stored history, and writes the
+// anomalies it finds to the store and to OutputDir.
+func detectAnomalies(store storage.Backend, window string) ([]anomaly.Anomaly, error) {
+	windowStart, _, _ := strings.Cut(window, ",")
+	anomalies, err := anomaly.Run(store, configs.AnomalyAggregations, windowStart, anomaly.Options{
+		Thresholds:     configs.AnomalyThresholds,
+		HistoryWindows: configs.AnomalyHistoryWindows,
+		MinHistory:     configs.AnomalyMinHistory,
+		MinCost:        configs.AnomalyMinCost,
+		MinChange:      configs.AnomalyMinChange,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := history.Encode(append([][]string{anomaly.Header}, anomaly.Records(anomalies)...))
+	if err != nil {
+		return nil, err
+	}
+	day := strings.SplitN(window, "T", 2)[0]
+	if err := store.Write(configs.AnomalyPrefix+day+".csv", content, "text/csv"); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(configs.OutputDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(configs.OutputDir, "Anomalies.csv"), content, 0644); err != nil {
+		return nil, err
+	}
+
+	for _, a := range anomalies {
+		configs.ErrorLogger.Println("Cost anomaly:", a)
+	}
+	configs.InfoLogger.Printf("%d cost anomalies written to %s/%s%s.csv\n", len(anomalies), store, configs.AnomalyPrefix, day)
+	return anomalies, nil
+}
+
+// detect runs the anomaly detection of -window on its own, e.g. for a past Window
+// or to try other thresholds.
+func detect(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("anomalies", flag.ExitOnError)
+	window := flags.String("window", configs.Window, "window to check, <start>,<end>")
+	flags.Parse(args)
+
+	if _, err := detectAnomalies(store, *window); err != nil {
+		configs.ErrorLogger.Println("Error detecting anomalies:", err)
+		os.Exit(1)
+	}
+}
+
+// evaluateBudgets writes the month-to-date status of every budget to the store and
+// to OutputDir, and logs and delivers the alerts raised by the Window. labelSpend
+// is the cost of the label budgets in the Window, nil when nothing was collected.
+func evaluateBudgets(store storage.Backend, window string, labelSpend map[string]float64) error {
+	statuses, alerts, err := budget.Evaluate(store, configs.Budgets, configs.BudgetThresholds, window, configs.ClusterName, configs.RunID, labelSpend)
+	if err != nil {
+		return err
+	}
+
+	content, err := history.Encode(append([][]string{budget.Header}, budget.Records(statuses)...))
+	if err != nil {
+		return err
+	}
+	day := strings.SplitN(window, "T", 2)[0]
+	key := configs.BudgetPrefix + configs.ClusterName + "/Status-" + day + ".csv"
+	if err := store.Write(key, content, "text/csv"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configs.OutputDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(configs.OutputDir, "BudgetStatus.csv"), content, 0644); err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		configs.InfoLogger.Printf("Budget %s: %.2f of %.2f spent in %s (%.1f%%), forecast %.2f of %.2f\n", s.Name, s.Spend, s.Allowed, s.Month, s.Used, s.Forecast, s.MonthBudget)
+	}
+
+	if len(alerts) == 0 {
+		return nil
+	}
+	for _, a := range alerts {
+		configs.ErrorLogger.Printf("Budget alert: %s reached %g%% in %s (%.2f of %.2f)\n", a.Budget, a.Threshold, a.Month, a.Spend, a.Allowed)
+	}
+	if len(configs.WebhookURLs) == 0 {
+		return nil
+	}
+	hook, err := webhook.Open(webhook.Config{
+		URLs:           configs.WebhookURLs,
+		Secret:         configs.WebhookSecret,
+		BatchSize:      configs.WebhookBatchSize,
+		MaxRetries:     configs.WebhookMaxRetries,
+		DeadLetterFile: configs.WebhookDeadLetterFile,
+		RunID:          configs.RunID,
+		Cluster:        configs.ClusterName,
+		Window:         window,
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, a := range alerts {
+		errs = append(errs, hook.Send(webhook.EventBudgetAlert, a))
+	}
+	return errors.Join(errs...)
+}
+
+// evaluateBudgetsOnly evaluates the budgets for -window without collecting, e.g.
+// after changing them. Label budgets use the spend recorded by earlier runs.
+func evaluateBudgetsOnly(store storage.Backend, args []string) {
+	flags := flag.NewFlagSet("budgets", flag.ExitOnError)
+	window := flags.String("window", configs.Window, "window whose month is evaluated, <start>,<end>")
+	flags.Parse(args)
+
+	if err := evaluateBudgets(store, *window, nil); err != nil {
+		configs.ErrorLogger.Println("Error evaluating budgets:", err)
+		os.Exit(1)
+	}
+}
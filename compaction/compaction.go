@@ -0,0 +1,343 @@
+package compaction
+
+import (
+	"errors"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run compacts the history of every aggregation. Rows older than the configured
+// retention are dropped, and the rows of every completed month are rolled into a
+// single monthly object: from the daily objects of the month partitioned layout,
+// or out of the single object (e.g. Pod/Pod.csv) of the single-file layout.
+func Run(store storage.Backend, now time.Time) error {
+	for _, aggregation := range schema.Aggregations {
+		if err := compactAggregation(store, aggregation, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compactAggregation(store storage.Backend, aggregation string, now time.Time) error {
+	var cutoff time.Time
+	if days := configs.RetentionDays[aggregation]; days > 0 {
+		cutoff = now.AddDate(0, 0, -days)
+	}
+
+	keys, err := store.List(aggregation + "/")
+	if err != nil {
+		return err
+	}
+
+	currentMonth := now.UTC().Format("2006-01")
+	partitions := map[string][]string{}
+	for _, key := range keys {
+		month, ok := partitionMonth(key)
+		if !ok {
+			if err := splitObject(store, aggregation, key, currentMonth, cutoff); err != nil {
+				return err
+			}
+			continue
+		}
+		partitions[month] = append(partitions[month], key)
+	}
+
+	for month, keys := range partitions {
+		sort.Strings(keys)
+		if month >= currentMonth {
+			for _, key := range keys {
+				if err := compactObject(store, key, cutoff); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := rollUpMonth(store, aggregation, month, keys, cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionMonth returns the month of a key in the partitioned layout, e.g.
+// "2024-07" for "Pod/month=2024-07/Pod-2024-07-27.csv".
+func partitionMonth(key string) (string, bool) {
+	for _, part := range strings.Split(key, "/") {
+		if month, ok := strings.CutPrefix(part, "month="); ok {
+			return month, true
+		}
+	}
+	return "", false
+}
+
+// monthlyKey is the object a completed month is rolled into.
+func monthlyKey(aggregation, month string) string {
+	return aggregation + "/month=" + month + "/" + aggregation + ".csv"
+}
+
+// skipModified turns a failed conditional write or delete into a log message: the
+// object was changed by a concurrent run and is compacted on the next run instead.
+func skipModified(err error, key string) error {
+	if errors.Is(err, storage.ErrPreconditionFailed) {
+		configs.InfoLogger.Printf("Compaction: %s was modified concurrently, skipping it until the next run\n", key)
+		return nil
+	}
+	return err
+}
+
+// compactObject drops the expired rows of a single object and rewrites it. The
+// rewrite, or the removal when every row expired, is conditional on the version
+// that was read, so rows appended by a concurrent run are never lost.
+func compactObject(store storage.Backend, key string, cutoff time.Time) error {
+	if cutoff.IsZero() {
+		return nil
+	}
+	object, err := store.Read(key)
+	if err != nil {
+		return err
+	}
+	records, err := history.Parse(object.Data)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	kept := expire(records, cutoff)
+	if len(kept) == len(records) {
+		return nil
+	}
+	if len(kept) == 1 {
+		if err := store.DeleteIf(key, object.Version); err != nil {
+			return skipModified(err, key)
+		}
+		configs.InfoLogger.Printf("Compaction: removed %s, all %d rows expired\n", key, len(records)-1)
+		return nil
+	}
+
+	data, err := history.Encode(kept)
+	if err != nil {
+		return err
+	}
+	if err := store.WriteIf(key, data, "text/csv", object.Version); err != nil {
+		return skipModified(err, key)
+	}
+	configs.InfoLogger.Printf("Compaction: dropped %d expired rows from %s\n", len(records)-len(kept), key)
+	return nil
+}
+
+// splitObject compacts an object of the single-file layout (e.g. Pod/Pod.csv): the
+// rows of completed months are moved to the monthly objects of the partitioned
+// layout, and the object keeps the rows of the current month. The monthly objects
+// are written first, so when the object was appended to concurrently and cannot be
+// rewritten, the moved rows are only duplicated until the next run merges them.
+func splitObject(store storage.Backend, aggregation, key, currentMonth string, cutoff time.Time) error {
+	object, err := store.Read(key)
+	if err != nil {
+		return err
+	}
+	records, err := history.Parse(object.Data)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	column := windowStartColumn(records[0])
+	kept := [][]string{records[0]}
+	months := map[string][][]string{}
+	for _, record := range expire(records, cutoff)[1:] {
+		if column >= 0 && column < len(record) {
+			if start, err := time.Parse(time.RFC3339, record[column]); err == nil {
+				if month := start.UTC().Format("2006-01"); month < currentMonth {
+					months[month] = append(months[month], record)
+					continue
+				}
+			}
+		}
+		kept = append(kept, record)
+	}
+	if len(months) == 0 && len(kept) == len(records) {
+		return nil
+	}
+
+	var names []string
+	for month := range months {
+		names = append(names, month)
+	}
+	sort.Strings(names)
+	for _, month := range names {
+		target := monthlyKey(aggregation, month)
+		objects := [][][]string{append([][]string{records[0]}, months[month]...)}
+		version := ""
+		existing, err := store.Read(target)
+		switch {
+		case err == nil:
+			version = existing.Version
+			rows, err := history.Parse(existing.Data)
+			if err != nil {
+				return err
+			}
+			if len(rows) > 0 {
+				objects = append([][][]string{rows}, objects...)
+			}
+		case !errors.Is(err, storage.ErrNotFound):
+			return err
+		}
+		n, err := writeMerged(store, aggregation, target, version, objects, cutoff)
+		if err != nil {
+			return skipModified(err, target)
+		}
+		configs.InfoLogger.Printf("Compaction: moved %d rows of %s from %s to %s (%d rows)\n", len(months[month]), month, key, target, n)
+	}
+
+	if len(kept) == 1 {
+		err = store.DeleteIf(key, object.Version)
+	} else {
+		var data []byte
+		if data, err = history.Encode(kept); err != nil {
+			return err
+		}
+		err = store.WriteIf(key, data, "text/csv", object.Version)
+	}
+	if err != nil {
+		return skipModified(err, key)
+	}
+	configs.InfoLogger.Printf("Compaction: %s keeps %d of %d rows\n", key, len(kept)-1, len(records)-1)
+	return nil
+}
+
+// writeMerged merges objects (each with its header first) into target, replacing
+// the version of target that was read ("" if it did not exist). Objects written
+// with an older header are migrated to the current one first. Identical rows are
+// merged, so a roll-up that was interrupted can safely be repeated. It returns the
+// number of rows written; when every row expired, target is removed instead.
+func writeMerged(store storage.Backend, aggregation, target, version string, objects [][][]string, cutoff time.Time) (int, error) {
+	// The merged object uses the current header, followed by any legacy columns
+	// found in the merged objects.
+	header := append([]string{}, schema.Header(aggregation)...)
+	for _, records := range objects {
+		header = append(header, history.Diff(records[0], header).Legacy...)
+	}
+
+	var rows [][]string
+	seen := map[string]bool{}
+	for _, records := range objects {
+		for _, record := range history.Migrate(records, header)[1:] {
+			id := strings.Join(record, "\x00")
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			rows = append(rows, record)
+		}
+	}
+
+	merged := expire(append([][]string{header}, rows...), cutoff)
+	if len(merged) == 1 {
+		if version == "" {
+			return 0, nil
+		}
+		return 0, store.DeleteIf(target, version)
+	}
+	data, err := history.Encode(merged)
+	if err != nil {
+		return 0, err
+	}
+	return len(merged) - 1, store.WriteIf(target, data, "text/csv", version)
+}
+
+// rollUpMonth merges the objects of a completed month into the monthly object and
+// deletes the daily objects afterwards. Every write and delete is conditional on
+// the version that was read; a daily object that was appended to in the meantime
+// is kept and merged again by the next run.
+func rollUpMonth(store storage.Backend, aggregation, month string, keys []string, cutoff time.Time) error {
+	target := monthlyKey(aggregation, month)
+	if len(keys) == 1 && keys[0] == target {
+		return compactObject(store, target, cutoff)
+	}
+
+	version := ""
+	versions := map[string]string{}
+	var objects [][][]string
+	for _, key := range keys {
+		object, err := store.Read(key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		versions[key] = object.Version
+		if key == target {
+			version = object.Version
+		}
+		records, err := history.Parse(object.Data)
+		if err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			objects = append(objects, records)
+		}
+	}
+
+	n, err := writeMerged(store, aggregation, target, version, objects, cutoff)
+	if err != nil {
+		return skipModified(err, target)
+	}
+
+	for _, key := range keys {
+		v, ok := versions[key]
+		if key == target || !ok {
+			continue
+		}
+		if err := store.DeleteIf(key, v); err != nil {
+			if err := skipModified(err, key); err != nil {
+				return err
+			}
+		}
+	}
+	configs.InfoLogger.Printf("Compaction: rolled %d objects of %s into %s (%d rows)\n", len(keys), month, target, n)
+	return nil
+}
+
+// windowStartColumn returns the index of the Window Start column, or -1.
+func windowStartColumn(header []string) int {
+	for i, name := range header {
+		if name == "Window Start" {
+			return i
+		}
+	}
+	return -1
+}
+
+// expire returns the header and the rows whose Window Start is not before cutoff.
+// Rows whose window cannot be parsed are kept.
+func expire(records [][]string, cutoff time.Time) [][]string {
+	if cutoff.IsZero() || len(records) == 0 {
+		return records
+	}
+	column := windowStartColumn(records[0])
+	if column < 0 {
+		return records
+	}
+
+	kept := [][]string{records[0]}
+	for _, record := range records[1:] {
+		if column < len(record) {
+			if start, err := time.Parse(time.RFC3339, record[column]); err == nil && start.Before(cutoff) {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	return kept
+}
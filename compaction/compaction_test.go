@@ -0,0 +1,176 @@
+package compaction
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 10, 15, 6, 0, 0, 0, time.UTC)
+
+// podRows returns a Pod object with one row per day of days, e.g. "2024-09-01".
+func podRows(t *testing.T, days ...string) []byte {
+	t.Helper()
+	header := schema.Header("Pod")
+	records := [][]string{header}
+	for _, day := range days {
+		record := make([]string, len(header))
+		record[0] = "pod-" + day
+		record[windowStartColumn(header)] = day + "T00:00:00Z"
+		records = append(records, record)
+	}
+	var buffer bytes.Buffer
+	if err := csv.NewWriter(&buffer).WriteAll(records); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// days returns the Window Start days of the rows stored at key.
+func days(t *testing.T, store storage.Backend, key string) []string {
+	t.Helper()
+	object, err := store.Read(key)
+	if err != nil {
+		t.Fatalf("%s: %v", key, err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(object.Data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := windowStartColumn(records[0])
+	var days []string
+	for _, record := range records[1:] {
+		days = append(days, record[column][:10])
+	}
+	sort.Strings(days)
+	return days
+}
+
+func newStore(t *testing.T, objects map[string][]byte) storage.Backend {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, data := range objects {
+		if err := store.Write(key, data, "text/csv"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func keys(t *testing.T, store storage.Backend) []string {
+	t.Helper()
+	keys, err := store.List("Pod/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withRetention sets the retention of the Pod rows for the duration of a test.
+func withRetention(t *testing.T, days int) {
+	previous, ok := configs.RetentionDays["Pod"]
+	configs.RetentionDays["Pod"] = days
+	t.Cleanup(func() {
+		if ok {
+			configs.RetentionDays["Pod"] = previous
+		} else {
+			delete(configs.RetentionDays, "Pod")
+		}
+	})
+}
+
+func TestRollUpPartitions(t *testing.T) {
+	withRetention(t, 0)
+	store := newStore(t, map[string][]byte{
+		"Pod/month=2024-09/Pod-2024-09-01.csv": podRows(t, "2024-09-01"),
+		"Pod/month=2024-09/Pod-2024-09-02.csv": podRows(t, "2024-09-02"),
+		"Pod/month=2024-10/Pod-2024-10-01.csv": podRows(t, "2024-10-01"),
+	})
+
+	// A second run finds the monthly object only and leaves it as it is.
+	for run := 0; run < 2; run++ {
+		if err := Run(store, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"Pod/month=2024-09/Pod.csv", "Pod/month=2024-10/Pod-2024-10-01.csv"}
+	if got := keys(t, store); !reflect.DeepEqual(got, want) {
+		t.Errorf("got objects %q, want %q", got, want)
+	}
+	if got := days(t, store, "Pod/month=2024-09/Pod.csv"); !reflect.DeepEqual(got, []string{"2024-09-01", "2024-09-02"}) {
+		t.Errorf("monthly object has %q", got)
+	}
+}
+
+func TestRollUpMergesRepeatedRows(t *testing.T) {
+	withRetention(t, 0)
+	// An interrupted roll-up left the daily objects next to the monthly object.
+	store := newStore(t, map[string][]byte{
+		"Pod/month=2024-09/Pod.csv":            podRows(t, "2024-09-01", "2024-09-02"),
+		"Pod/month=2024-09/Pod-2024-09-02.csv": podRows(t, "2024-09-02"),
+		"Pod/month=2024-09/Pod-2024-09-03.csv": podRows(t, "2024-09-03"),
+	})
+	if err := Run(store, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(t, store); !reflect.DeepEqual(got, []string{"Pod/month=2024-09/Pod.csv"}) {
+		t.Errorf("got objects %q", got)
+	}
+	if got := days(t, store, "Pod/month=2024-09/Pod.csv"); !reflect.DeepEqual(got, []string{"2024-09-01", "2024-09-02", "2024-09-03"}) {
+		t.Errorf("monthly object has %q", got)
+	}
+}
+
+func TestSplitSingleFile(t *testing.T) {
+	withRetention(t, 0)
+	store := newStore(t, map[string][]byte{
+		"Pod/Pod.csv": podRows(t, "2024-08-31", "2024-09-01", "2024-10-01", "2024-10-02"),
+	})
+	if err := Run(store, now); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Pod/Pod.csv", "Pod/month=2024-08/Pod.csv", "Pod/month=2024-09/Pod.csv"}
+	if got := keys(t, store); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got objects %q, want %q", got, want)
+	}
+	if got := days(t, store, "Pod/Pod.csv"); !reflect.DeepEqual(got, []string{"2024-10-01", "2024-10-02"}) {
+		t.Errorf("Pod/Pod.csv keeps %q", got)
+	}
+	if got := days(t, store, "Pod/month=2024-09/Pod.csv"); !reflect.DeepEqual(got, []string{"2024-09-01"}) {
+		t.Errorf("September has %q", got)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	withRetention(t, 30) // rows before 2024-09-15 expire
+	store := newStore(t, map[string][]byte{
+		"Pod/month=2024-08/Pod.csv":            podRows(t, "2024-08-01", "2024-08-31"),
+		"Pod/month=2024-09/Pod-2024-09-01.csv": podRows(t, "2024-09-01"),
+		"Pod/month=2024-09/Pod-2024-09-20.csv": podRows(t, "2024-09-20"),
+		"Pod/month=2024-10/Pod-2024-10-01.csv": podRows(t, "2024-10-01"),
+	})
+	if err := Run(store, now); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Pod/month=2024-09/Pod.csv", "Pod/month=2024-10/Pod-2024-10-01.csv"}
+	if got := keys(t, store); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got objects %q, want %q", got, want)
+	}
+	if got := days(t, store, "Pod/month=2024-09/Pod.csv"); !reflect.DeepEqual(got, []string{"2024-09-20"}) {
+		t.Errorf("September keeps %q", got)
+	}
+	if _, err := store.Read("Pod/month=2024-08/Pod.csv"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expired month not removed: %v", err)
+	}
+}
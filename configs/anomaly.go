@@ -0,0 +1,21 @@
+package configs
+
+const (
+	DetectAnomalies       = false // Compare every collected Window with the stored history after the run
+	AnomalyHistoryWindows = 14    // Previous windows compared with
+	AnomalyMinHistory     = 7     // Previous windows the zscore and median methods need
+	AnomalyMinCost        = 1.0   // Objects whose cost and expected cost are both lower are skipped
+	AnomalyMinChange      = 20.0  // Percent of the expected cost a window must differ by to be reported
+
+	// AnomalyPrefix is where the anomalies are stored, as <prefix><day>.csv.
+	AnomalyPrefix = "Anomalies/Anomalies-"
+)
+
+// AnomalyThresholds enables a detection method by giving it a threshold:
+//   - "zscore": standard deviations from the mean of the history
+//   - "percent": percent change from the previous window
+//   - "median": percent deviation from the median of the history
+var AnomalyThresholds = map[string]float64{"zscore": 3, "percent": 50, "median": 50}
+
+// AnomalyAggregations are checked for anomalies.
+var AnomalyAggregations = []string{"Namespace", "Deployment", "Controller"}
@@ -0,0 +1,13 @@
+package configs
+
+const (
+	AthenaDatabase = "kubecost" // Glue/Athena database the tables are created in
+	AthenaTableFormat = "csv"   // "csv" for the objects written by this tool, "parquet" if they are converted (e.g. by a CTAS job)
+
+	// PartitionProjectionStart is the first month Athena partition projection considers when PartitionByMonth is enabled.
+	PartitionProjectionStart = "2024-01"
+
+	// RegisterGlueTables creates or updates the tables through the Glue API after each run.
+	// The generated DDL is always written to Output/Athena/ regardless of this setting.
+	RegisterGlueTables = false
+)
@@ -0,0 +1,29 @@
+package configs
+
+// Budget is a spending limit for the costs selected by Scope and Match.
+type Budget struct {
+	Name string // Identifies the budget in the status rows and alerts; must be unique
+
+	// Scope is "namespace", "cluster" or "label". Match is a namespace or cluster name
+	// or pattern (e.g. "team-a-*"), or "key=value" for a label of the pods, with the
+	// key as Kubecost reports it (e.g. "app_kubernetes_io_team=payments").
+	Scope string
+	Match string
+
+	// Period is "monthly" (Amount is the budget of the calendar month) or "daily"
+	// (Amount is allowed per day, so the month-to-date budget grows every day).
+	Period string
+	Amount float64
+}
+
+// Budgets are evaluated after every run. Example -
+// {{Name: "payments", Scope: "namespace", Match: "payments", Period: "monthly", Amount: 2000}}
+var Budgets = []Budget{}
+
+// BudgetThresholds are the percentages of a budget that raise an alert, each at most
+// once per budget and month.
+var BudgetThresholds = []float64{50, 80, 100}
+
+// BudgetPrefix is where the budget status (<cluster>/Status-<day>.csv) and the state of
+// each month (<cluster>/State-<month>.json, with label spend and fired alerts) are stored.
+const BudgetPrefix = "Budgets/"
@@ -0,0 +1,14 @@
+package configs
+
+const (
+	// ClickHouseEndpoint enables the ClickHouse sink, using the HTTP interface.
+	// Leave empty to disable it. Example - http://localhost:8123
+	ClickHouseEndpoint = ""
+	ClickHouseDatabase = "kubecost" // Database the per-aggregation tables are created in
+	ClickHouseUser     = ""         // Leave empty to use the server's default user
+	ClickHousePassword = ""
+)
+
+// ClickHouseAggregations lists the aggregations written to ClickHouse. Leave empty
+// to write all of them.
+var ClickHouseAggregations = []string{"Pod", "Controller"}
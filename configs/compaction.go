@@ -0,0 +1,28 @@
+package configs
+
+import "strings"
+
+// PartitionByMonth writes every run to its own object under a Hive style month partition
+// (e.g. Pod/month=2024-07/Pod-2024-07-27.csv) instead of appending to a single Pod/Pod.csv.
+// Either way, the compact command rolls completed months into Pod/month=2024-07/Pod.csv.
+const PartitionByMonth = false
+
+// ObjectKey returns the key the given aggregation (e.g. "Pod") is written to for the current Window.
+func ObjectKey(aggregation string) string {
+	if !PartitionByMonth {
+		return aggregation + "/" + aggregation + ".csv"
+	}
+	day := strings.SplitN(Window, "T", 2)[0]
+	return aggregation + "/month=" + day[:7] + "/" + aggregation + "-" + day + ".csv"
+}
+
+// RetentionDays is how long the rows of each aggregation are kept by the
+// "compact" command, based on their Window Start. Aggregations that are missing
+// or set to 0 are kept forever.
+var RetentionDays = map[string]int{
+	"Pod":        90,
+	"Service":    90,
+	"Controller": 180,
+	"Rollout":    180,
+	// "Namespace": 0,
+}
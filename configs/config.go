@@ -13,19 +13,18 @@ const (
 	ClusterName = "<Cluster-Name>"
 	BucketName = "<bucket-name>"
 	BucketRegion = "<bucket-region>"
+	OutputDir = "Output" // Local copies of the CSVs and generated files are written here
 )
 
 var (
 	InfoLogger  *log.Logger
 	ErrorLogger *log.Logger
 
-	enddate = time.Now().Format("2006-01-02")
-	startdate = time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	Window = YesterdayWindow(time.Now())    // Window represents the time range of yesterday. (Format - 2024-07-27T00:00:00Z,2024-07-28T00:00:00Z)
 
-	start = startdate + "T00:00:00Z"
-	end = enddate + "T00:00:00Z"
-	Window = start + "," + end    // Window represents the time range of yesterday. (Format - 2024-07-27T00:00:00Z,2024-07-28T00:00:00Z)
+	RunID = time.Now().UTC().Format("20060102T150405Z") // Identifies this run in the manifest and in published records
 
+	Sess *session.Session
 	Svc *s3.S3
 )
 
@@ -33,6 +32,12 @@ func init() {
 	InfoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// The AWS session is only needed for the S3 backend and Glue, so local-only
+	// runs work without any AWS configuration.
+	if StorageBackend != "s3" && !RegisterGlueTables {
+		return
+	}
+
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(BucketRegion),
 	})
@@ -41,5 +46,18 @@ func init() {
 		return
 	}
 
-	Svc = s3.New(sess)
-}
\ No newline at end of file
+	Sess = sess
+
+	s3Config := &aws.Config{S3ForcePathStyle: aws.Bool(S3ForcePathStyle)}
+	if S3Endpoint != "" {
+		s3Config.Endpoint = aws.String(S3Endpoint)
+	}
+	Svc = s3.New(sess, s3Config)
+}
+
+// YesterdayWindow returns the Window of the day before now.
+func YesterdayWindow(now time.Time) string {
+	start := now.AddDate(0, 0, -1).Format("2006-01-02") + "T00:00:00Z"
+	end := now.Format("2006-01-02") + "T00:00:00Z"
+	return start + "," + end
+}
@@ -0,0 +1,12 @@
+package configs
+
+const (
+	DashboardTitle    = "Kubecost Efficiency"
+	DashboardDays     = 30 // Days covered by the dashboard, ending with the configured Window; 0 for all history
+	DashboardTopN     = 10 // Top wasters listed per cluster
+	DashboardCurrency = "$"
+
+	// DashboardKey is where "report -publish" stores the page. With S3 static website
+	// hosting enabled on BucketName it is served at http://<bucket>.s3-website-<region>.amazonaws.com/Dashboard/
+	DashboardKey = "Dashboard/index.html"
+)
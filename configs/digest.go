@@ -0,0 +1,26 @@
+package configs
+
+// DigestChannel is an incoming webhook the daily digest is posted to.
+type DigestChannel struct {
+	Format string // "slack" (Block Kit) or "teams" (Adaptive Card)
+	URL    string
+}
+
+// DigestChannels receive the digest of the whole cluster after every run. Leave
+// empty to disable it. Example - {{Format: "slack", URL: "https://hooks.slack.com/services/..."}}
+var DigestChannels = []DigestChannel{}
+
+// DigestNamespaceRoutes send a digest covering only one namespace to the channels
+// of its team, e.g. {"payments": {{Format: "teams", URL: "https://..."}}}.
+var DigestNamespaceRoutes = map[string][]DigestChannel{}
+
+const (
+	DigestTopN     = 5   // Namespaces, deployments and changes listed
+	DigestCurrency = "$" // Prefix of the cost values
+
+	// DigestTitleTemplate and DigestSummaryTemplate are text/template strings executed
+	// with the digest (.Cluster, .Namespace, .Window, .TotalCost, .PreviousTotalCost,
+	// .Efficiency). The functions money, percent and change format the values.
+	DigestTitleTemplate   = `Kubecost daily digest: {{.Cluster}}{{with .Namespace}} / {{.}}{{end}}`
+	DigestSummaryTemplate = `Total cost *{{money .TotalCost}}*{{if .PreviousTotalCost}} ({{change .TotalCost .PreviousTotalCost}} day over day){{end}}, efficiency *{{percent .Efficiency}}*`
+)
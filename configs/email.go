@@ -0,0 +1,32 @@
+package configs
+
+const (
+	SMTPHost     = "" // Mail server of the email report. Example - smtp.example.com
+	SMTPPort     = 587
+	SMTPUsername = "" // PLAIN auth, used when set
+	SMTPPassword = ""
+	SMTPStartTLS = true // Upgrade the connection with STARTTLS; disable only for a local test server
+
+	EmailFrom       = "Kubecost Reports <kubecost-reports@example.com>"
+	EmailReportDays = 7  // Days covered by the report, ending with the configured Window
+	EmailTopN       = 10 // Deployments listed per namespace
+	EmailCurrency   = "$"
+)
+
+// EmailRecipients receive the full report with the cluster overview and every namespace.
+var EmailRecipients = []string{}
+
+// EmailNamespaceRecipients send each team only the sections of its namespaces. Keys are
+// namespace names or patterns such as "team-a-*", e.g. {"payments": {"payments-team@example.com"}}.
+var EmailNamespaceRecipients = map[string][]string{}
+
+// EmailLabelRecipients send each team only the sections of the namespaces whose pods
+// carry a label, as "key=value" with the key as Kubecost reports it, e.g.
+// {"app_kubernetes_io_team=payments": {"payments-team@example.com"}}. Labels are not
+// kept in the history: while this is set, every run records the pod labels of each
+// namespace at EmailLabelsKey, and only namespaces labelled during the report period
+// are routed.
+var EmailLabelRecipients = map[string][]string{}
+
+// EmailLabelsKey stores the pod labels recorded for EmailLabelRecipients.
+const EmailLabelsKey = "Email/Labels-" + ClusterName + ".json"
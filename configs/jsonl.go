@@ -0,0 +1,6 @@
+package configs
+
+// ExportJSONL writes the rows of every run as JSON Lines, with the Kubecost properties
+// (labels, annotations, node, controller, ...) kept nested. Objects are stored as
+// JSONL/<Agg>/<Agg>-<ClusterName>-<day>.jsonl and copied to OutputDir.
+const ExportJSONL = false
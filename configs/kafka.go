@@ -0,0 +1,20 @@
+package configs
+
+import "time"
+
+// KafkaBrokers enables the Kafka sink. Leave empty to disable it.
+// Example - []string{"localhost:9092"}
+var KafkaBrokers = []string{}
+
+// KafkaTopics overrides the topic of single aggregations, e.g. {"Pod": "finops.pods"}.
+var KafkaTopics = map[string]string{}
+
+const (
+	KafkaTopicPrefix = "kubecost." // Default topic of an aggregation is the prefix + its snake_case name, e.g. kubecost.controller_kind
+
+	// KafkaRequiredAcks is the delivery guarantee: -1 waits for all in-sync replicas,
+	// 1 for the partition leader only, 0 does not wait.
+	KafkaRequiredAcks = -1
+	KafkaBatchSize    = 100                    // Messages sent per produce request
+	KafkaBatchTimeout = 100 * time.Millisecond // Longest a partial batch waits before it is sent
+)
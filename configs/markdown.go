@@ -0,0 +1,14 @@
+package configs
+
+const (
+	MarkdownTitle    = "Kubecost cost report"
+	MarkdownTopN     = 10 // Rows per table
+	MarkdownCurrency = "$"
+)
+
+// MarkdownSections are written in this order. Available: "totals", "cost", "waste" and
+// "efficiency" (efficiency changes, only written when comparing two windows).
+var MarkdownSections = []string{"totals", "cost", "waste", "efficiency"}
+
+// MarkdownAggregations get a table in the cost, waste and efficiency sections.
+var MarkdownAggregations = []string{"Namespace", "Deployment", "Controller"}
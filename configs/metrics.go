@@ -0,0 +1,19 @@
+package configs
+
+import "time"
+
+const (
+	MetricsListenAddress = ":9108" // Address the serve command exposes /metrics on
+
+	// MetricsRefreshInterval is how often the serve command checks for a new Window.
+	// A Window is collected once, when the day changes.
+	MetricsRefreshInterval = time.Hour
+
+	MetricsDefaultCardinalityLimit = 1000 // Series per aggregation, the most expensive allocations are kept
+)
+
+// MetricsCardinalityLimits overrides MetricsDefaultCardinalityLimit per aggregation.
+// A limit of 0 excludes the aggregation from /metrics.
+var MetricsCardinalityLimits = map[string]int{
+	"Pod": 0,
+}
@@ -0,0 +1,12 @@
+package configs
+
+const (
+	// OTLPEndpoint enables exporting the collected costs and efficiencies as OTLP metrics,
+	// e.g. to an OpenTelemetry Collector. Leave empty to disable it. An http:// URL
+	// disables TLS. Example - http://otel-collector:4317 (grpc) or http://otel-collector:4318 (http/protobuf)
+	OTLPEndpoint = ""
+	OTLPProtocol = "grpc" // grpc or http/protobuf
+)
+
+// OTLPHeaders are sent with every export request, e.g. {"Authorization": "Bearer <token>"}.
+var OTLPHeaders = map[string]string{}
@@ -0,0 +1,21 @@
+package configs
+
+const (
+	// RemoteWriteURL enables pushing the collected costs and efficiencies to a Prometheus
+	// remote-write endpoint. Leave empty to disable it.
+	// Example - http://mimir:9009/api/v1/push or http://victoriametrics:8428/api/v1/write
+	RemoteWriteURL = ""
+
+	RemoteWriteUsername    = "" // Basic auth, used when set
+	RemoteWritePassword    = ""
+	RemoteWriteBearerToken = "" // Used when set and RemoteWriteUsername is empty
+
+	RemoteWriteBatchSize  = 500 // Series per request
+	RemoteWriteMaxRetries = 3   // Retries of a request on network errors, 429 and 5xx responses
+)
+
+// RemoteWriteLabels are added to every pushed series, e.g. {"env": "prod"}.
+var RemoteWriteLabels = map[string]string{}
+
+// RemoteWriteAggregations lists the aggregations pushed. Leave empty to push all of them.
+var RemoteWriteAggregations = []string{"Cluster", "Namespace", "Deployment", "Controller", "Rollout"}
@@ -0,0 +1,17 @@
+package configs
+
+const (
+	RightsizingDays              = 14   // Days of history used, ending with the configured Window
+	RightsizingPercentile        = 95   // Percentile of the daily usage averages sized for; 100 for the busiest day
+	RightsizingTargetUtilization = 0.8  // Share of the recommended request the usage should fill
+	RightsizingHeadroom          = 0.1  // Added on top of the usage before applying the target utilization
+	RightsizingMinCPUCores       = 0.01 // Smallest recommended CPU request
+	RightsizingMinRAMBytes       = 16 << 20
+
+	// RightsizingPrefix is where the recommendations are stored, as <prefix><day>.csv.
+	RightsizingPrefix = "Rightsizing/Rightsizing-"
+)
+
+// RightsizingAggregations get recommendations. Only these aggregations collect the
+// request and usage averages.
+var RightsizingAggregations = []string{"Deployment", "Controller", "Rollout"}
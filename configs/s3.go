@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"net/url"
+	"sort"
+)
+
+const (
+	S3Endpoint       = ""    // Leave empty for AWS. Example for MinIO - http://minio.example.internal:9000
+	S3ForcePathStyle = false // Required by most S3-compatible stores such as MinIO
+
+	ServerSideEncryption = "" // "AES256" or "aws:kms". Empty uses the bucket default.
+	SSEKMSKeyID          = "" // KMS key ID or ARN, used when ServerSideEncryption is "aws:kms"
+	StorageClass         = "" // Example - STANDARD_IA, INTELLIGENT_TIERING
+	ObjectACL            = "" // Canned ACL. Example - bucket-owner-full-control
+)
+
+// ObjectTags are attached to every object written to the bucket, e.g. for cost allocation.
+var ObjectTags = map[string]string{
+	// "team": "finops",
+}
+
+// NewPutObjectInput builds a PutObject request applying the encryption, storage
+// class, ACL and tag settings above.
+func NewPutObjectInput(bucketName, key string, body []byte, contentType string) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	}
+	if ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(ServerSideEncryption)
+		if ServerSideEncryption == s3.ServerSideEncryptionAwsKms && SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(SSEKMSKeyID)
+		}
+	}
+	if StorageClass != "" {
+		input.StorageClass = aws.String(StorageClass)
+	}
+	if ObjectACL != "" {
+		input.ACL = aws.String(ObjectACL)
+	}
+	if len(ObjectTags) > 0 {
+		keys := make([]string, 0, len(ObjectTags))
+		for k := range ObjectTags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		tags := url.Values{}
+		for _, k := range keys {
+			tags.Set(k, ObjectTags[k])
+		}
+		input.Tagging = aws.String(tags.Encode())
+	}
+	return input
+}
@@ -0,0 +1,5 @@
+package configs
+
+// SQLitePath enables the SQLite sink, which accumulates every collected row in a
+// local database file. Leave empty to disable it. Example - Output/history.db
+const SQLitePath = ""
@@ -0,0 +1,17 @@
+package configs
+
+const (
+	// StorageBackend selects where the history is kept: "s3", "local", "gcs" or "azure".
+	// BucketName is used as the bucket (S3, GCS) or container (Azure) name.
+	StorageBackend = "s3"
+
+	// LocalStorageDir is the root directory of the "local" backend. With "local" the
+	// tool runs without any AWS configuration and reads its history from here.
+	LocalStorageDir = OutputDir
+
+	GCSEndpoint = "" // Leave empty for Google Cloud Storage. Example for fake-gcs-server - http://localhost:4443/storage/v1/
+
+	// AzureConnectionString of the storage account. Example for Azurite -
+	// DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=<key>;BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;
+	AzureConnectionString = ""
+)
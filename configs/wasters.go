@@ -0,0 +1,20 @@
+package configs
+
+const (
+	WastersDays    = 7   // Days ranked, ending with the configured Window
+	WastersTopN    = 20  // Wasters listed per aggregation; 0 for all
+	WastersMinCost = 1.0 // Workloads costing less over the period are not listed
+
+	// WastersPrefix is where the ranking is stored, as <prefix><day>.csv.
+	WastersPrefix = "Wasters/Wasters-"
+)
+
+// WastersAggregations are ranked, each on its own.
+var WastersAggregations = []string{"Namespace", "Deployment", "Controller", "Pod"}
+
+// WastersNamespaces keeps only these namespaces, given as names or patterns such as
+// "team-a-*". Empty keeps every namespace.
+var WastersNamespaces = []string{}
+
+// WastersExcludeNamespaces are never listed.
+var WastersExcludeNamespaces = []string{"kube-system"}
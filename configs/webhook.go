@@ -0,0 +1,19 @@
+package configs
+
+// WebhookURLs enables the webhook output. Every URL receives the events as JSON POST
+// requests. Leave empty to disable it. Example - []string{"https://finops.example.com/hooks/kubecost"}
+var WebhookURLs = []string{}
+
+const (
+	// WebhookSecret signs every request: the X-Kubecost-Signature-256 header carries
+	// "sha256=" and the hex HMAC-SHA256 of the body. It is required with WebhookURLs.
+	WebhookSecret = ""
+
+	WebhookSendSummary = true  // POST a run.finished event with the rows and total cost per aggregation
+	WebhookSendRecords = false // POST the collected rows as allocations events
+	WebhookBatchSize   = 500   // Rows per allocations event
+	WebhookMaxRetries  = 5     // Retries of a delivery on network errors, 429 and 5xx responses
+
+	// WebhookDeadLetterFile receives the deliveries that still failed after the retries, one JSON line each.
+	WebhookDeadLetterFile = OutputDir + "/webhook-dead-letter.jsonl"
+)
@@ -0,0 +1,5 @@
+package configs
+
+// ExportXLSX writes the rows of every run to a workbook with a summary sheet and one
+// sheet per aggregation, stored as Excel/Kubecost-<ClusterName>-<day>.xlsx and in OutputDir.
+const ExportXLSX = false
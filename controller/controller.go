@@ -1,24 +1,24 @@
 package controller
 
 import (
-	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"kubecost-efficiency-fetcher/allocation"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 	"time"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"regexp"
 )
 
-func FetchAndWriteControllerData(inputURL, clusterName, window, bucketName, region string, wg *sync.WaitGroup) {
+func FetchAndWriteControllerData(inputURL, clusterName, window, region string, store storage.Backend, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 	u, err := url.Parse(inputURL)
@@ -66,88 +66,14 @@ func FetchAndWriteControllerData(inputURL, clusterName, window, bucketName, regi
 
 	data := result["data"].([]interface{})
 
-	svc := configs.Svc
-
-	objectKeyController := "Controller/Controller.csv"
-	objectKeyRollout := "Rollout/Rollout.csv"
-
-	existingData := [][]string{}
-	rolloutData := [][]string{}
-	fileExistsController := false
-	fileExistsRollout := false
-
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKeyController),
-	})
-	if err == nil {
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKeyController),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing Controller file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		reader := csv.NewReader(resp.Body)
-		existingData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing Controller CSV data:", err)
-			return
-		}
-		fileExistsController = true
-	} else {
-		configs.InfoLogger.Println("No existing Controller.csv file found. A new one will be created.")
-	}
-
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKeyRollout),
-	})
-	if err == nil {
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKeyRollout),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing Rollout file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
+	objectKeyController := configs.ObjectKey("Controller")
+	objectKeyRollout := configs.ObjectKey("Rollout")
 
-		reader := csv.NewReader(resp.Body)
-		rolloutData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing Rollout CSV data:", err)
-			return
-		}
-		fileExistsRollout = true
-	} else {
-		configs.InfoLogger.Println("No existing Rollout.csv file found. A new one will be created.")
-	}
-
-	var bufferController, bufferRollout bytes.Buffer
-	writerController := csv.NewWriter(&bufferController)
-	writerRollout := csv.NewWriter(&bufferRollout)
-	defer writerController.Flush()
-	defer writerRollout.Flush()
-
-	if !fileExistsController {
-		header := []string{"Controller", "ClusterName", "Region", "Namespace", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency"}
-		if err := writerController.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to Controller CSV:", err)
-			return
-		}
-	}
-	if !fileExistsRollout {
-		header := []string{"Rollout", "ClusterName", "Region", "Namespace", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency"}
-		if err := writerRollout.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to Rollout CSV:", err)
-			return
-		}
-	}
+	controllerRecords := [][]string{}
+	rolloutRecords := [][]string{}
+	controllerAllocations := []allocation.Allocation{}
+	rolloutAllocations := []allocation.Allocation{}
+	var missing []string
 
 	re := regexp.MustCompile(`-[^-]+$`)
 
@@ -203,79 +129,105 @@ func FetchAndWriteControllerData(inputURL, clusterName, window, bucketName, regi
 			cpuEfficiency := controllerOne["cpuEfficiency"].(float64) * 100
 			ramEfficiency := controllerOne["ramEfficiency"].(float64) * 100
 			totalEfficiency := controllerOne["totalEfficiency"].(float64) * 100
-
-			record := []string{
-				name, clusterName, region, namespaceController, windowStart, windowEnd,
-				fmt.Sprintf("%f", cpuCost), fmt.Sprintf("%f", gpuCost),
-				fmt.Sprintf("%f", ramCost), fmt.Sprintf("%f", pvCost),
-				fmt.Sprintf("%f", networkCost), fmt.Sprintf("%f", loadBalancerCost),
-				fmt.Sprintf("%f", totalCost),
-				fmt.Sprintf("%f", cpuEfficiency), fmt.Sprintf("%f", ramEfficiency),
-				fmt.Sprintf("%f", totalEfficiency),
+			cpuWaste := allocation.Waste(cpuCost, cpuEfficiency)
+			ramWaste := allocation.Waste(ramCost, ramEfficiency)
+			cpuCoreRequestAverage, cpuCoreUsageAverage, ramBytesRequestAverage, ramBytesUsageAverage := allocation.Averages(controllerOne, &missing)
+
+			alloc := allocation.Allocation{
+				Aggregation:      "Controller",
+				Name:             name,
+				Cluster:          clusterName,
+				Region:           region,
+				Namespace:        namespaceController,
+				WindowStart:      windowStart,
+				WindowEnd:        windowEnd,
+				CpuCost:          cpuCost,
+				GpuCost:          gpuCost,
+				RamCost:          ramCost,
+				PVCost:           pvCost,
+				NetworkCost:      networkCost,
+				LoadBalancerCost: loadBalancerCost,
+				TotalCost:        totalCost,
+				CpuEfficiency:    cpuEfficiency,
+				RamEfficiency:    ramEfficiency,
+				TotalEfficiency:  totalEfficiency,
+				CpuWaste:         cpuWaste,
+				RamWaste:         ramWaste,
+				Properties:       properties,
+
+				CpuCoreRequestAverage:  cpuCoreRequestAverage,
+				CpuCoreUsageAverage:    cpuCoreUsageAverage,
+				RamBytesRequestAverage: ramBytesRequestAverage,
+				RamBytesUsageAverage:   ramBytesUsageAverage,
 			}
-
-			existingData = append(existingData, record)
+			controllerAllocations = append(controllerAllocations, alloc)
+			controllerRecords = append(controllerRecords, record(alloc))
 
 			if strings.HasPrefix(name, "rollout:") {
 				nameWithoutRollout := strings.TrimPrefix(name, "rollout:")
 				nameWithoutRolloutSuffix := re.ReplaceAllString(nameWithoutRollout, "")
-				
-				rolloutRecord := make([]string, len(record))
-				copy(rolloutRecord, record)                  
-				rolloutRecord[0] = nameWithoutRolloutSuffix     
-				rolloutData = append(rolloutData, rolloutRecord)
-			}
-
-		}
 
-		if err := writerController.WriteAll(existingData); err != nil {
-			configs.ErrorLogger.Println("Error writing Controller data to CSV:", err)
-			return
-		}
+				rolloutAlloc := alloc
+				rolloutAlloc.Aggregation = "Rollout"
+				rolloutAlloc.Name = nameWithoutRolloutSuffix
+				rolloutAllocations = append(rolloutAllocations, rolloutAlloc)
+			}
 
-		if err := writerRollout.WriteAll(rolloutData); err != nil {
-			configs.ErrorLogger.Println("Error writing Rollout data to CSV:", err)
-			return
 		}
+	}
+	if len(missing) > 0 {
+		configs.ErrorLogger.Printf("Controller data without %s, rightsizing reads them as 0\n", strings.Join(missing, ", "))
+	}
 
-		err = os.MkdirAll("Output", 0755)
-		if err != nil {
-			configs.ErrorLogger.Println("Error creating directory:", err)
-			return
-		}
+	// Trimming the suffix gives the replica sets of a rollout the same name, so
+	// their rows are summed into one row per rollout, namespace and window.
+	rolloutAllocations = allocation.Merge(rolloutAllocations)
+	for _, a := range rolloutAllocations {
+		rolloutRecords = append(rolloutRecords, record(a))
+	}
 
-		err = os.WriteFile("Output/Controller.csv", bufferController.Bytes(), 0644)
-		if err != nil {
-			configs.ErrorLogger.Println("Error saving Controller.csv file locally:", err)
-			return
-		}
+	contentController, err := history.Append(store, objectKeyController, schema.Controller, controllerRecords)
+	if err != nil {
+		configs.ErrorLogger.Println("Error uploading Controller.csv file to storage:", err)
+		return
+	}
 
-		err = os.WriteFile("Output/Rollout.csv", bufferRollout.Bytes(), 0644)
-		if err != nil {
-			configs.ErrorLogger.Println("Error saving Rollout.csv file locally:", err)
-			return
-		}
+	contentRollout, err := history.Append(store, objectKeyRollout, schema.Rollout, rolloutRecords)
+	if err != nil {
+		configs.ErrorLogger.Println("Error uploading Rollout.csv file to storage:", err)
+		return
+	}
 
-		_, err = svc.PutObject(&s3.PutObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKeyController),
-			Body:   bytes.NewReader(bufferController.Bytes()),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error uploading Controller.csv file to S3:", err)
-			return
-		}
+	err = storage.WriteLocalCopy("Controller.csv", contentController)
+	if err != nil {
+		configs.ErrorLogger.Println("Error saving Controller.csv file locally:", err)
+		return
+	}
 
-		_, err = svc.PutObject(&s3.PutObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKeyRollout),
-			Body:   bytes.NewReader(bufferRollout.Bytes()),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error uploading Rollout.csv file to S3:", err)
-			return
-		}
+	err = storage.WriteLocalCopy("Rollout.csv", contentRollout)
+	if err != nil {
+		configs.ErrorLogger.Println("Error saving Rollout.csv file locally:", err)
+		return
+	}
 
-		configs.InfoLogger.Println("Controller and Rollout data successfully written to S3.")
+	sink.Publish("Controller", controllerAllocations)
+	sink.Publish("Rollout", rolloutAllocations)
+
+	configs.InfoLogger.Println("Controller and Rollout data successfully written to", store)
+}
+
+// record returns the CSV record of a Controller or Rollout allocation.
+func record(a allocation.Allocation) []string {
+	return []string{
+		a.Name, a.Cluster, a.Region, a.Namespace, a.WindowStart, a.WindowEnd,
+		fmt.Sprintf("%f", a.CpuCost), fmt.Sprintf("%f", a.GpuCost),
+		fmt.Sprintf("%f", a.RamCost), fmt.Sprintf("%f", a.PVCost),
+		fmt.Sprintf("%f", a.NetworkCost), fmt.Sprintf("%f", a.LoadBalancerCost),
+		fmt.Sprintf("%f", a.TotalCost),
+		fmt.Sprintf("%f", a.CpuEfficiency), fmt.Sprintf("%f", a.RamEfficiency),
+		fmt.Sprintf("%f", a.TotalEfficiency),
+		fmt.Sprintf("%f", a.CpuCoreRequestAverage), fmt.Sprintf("%f", a.CpuCoreUsageAverage),
+		fmt.Sprintf("%f", a.RamBytesRequestAverage), fmt.Sprintf("%f", a.RamBytesUsageAverage),
+		fmt.Sprintf("%f", a.CpuWaste), fmt.Sprintf("%f", a.RamWaste),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,101 @@
+package dashboard
+
+import (
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/report"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"time"
+)
+
+// Cluster is the section of one cluster.
+type Cluster struct {
+	Name       string
+	TotalCost  float64
+	Efficiency float64
+	Daily      []report.Day // oldest first
+
+	Namespaces  []report.Line // by cost, most expensive first
+	Deployments []report.Line // by cost, most expensive first
+	Wasters     []report.Line // by waste, at most topN
+}
+
+// Dashboard is the content of the HTML page.
+type Dashboard struct {
+	Start, End time.Time // the zero Start means all of the history
+	Generated  time.Time
+	Clusters   []Cluster // by name
+}
+
+// Build reads the Cluster, Namespace and Deployment history of the Windows between
+// start and end and groups it by cluster, so a bucket shared by several collectors
+// gets one section per cluster.
+func Build(store storage.Backend, start, end time.Time, topN int) (*Dashboard, error) {
+	loaded := map[string]map[string][]allocation.Allocation{}
+	for _, aggregation := range []string{"Cluster", "Namespace", "Deployment"} {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		byCluster := map[string][]allocation.Allocation{}
+		for _, a := range report.InPeriod(allocations, start, end) {
+			byCluster[a.Cluster] = append(byCluster[a.Cluster], a)
+		}
+		loaded[aggregation] = byCluster
+	}
+
+	d := &Dashboard{Start: start, End: end, Generated: time.Now().UTC()}
+	for name, allocations := range loaded["Cluster"] {
+		c := Cluster{Name: name}
+		c.Daily, c.TotalCost, c.Efficiency = daily(allocations)
+		c.Namespaces = report.Lines(loaded["Namespace"][name])
+		c.Deployments = report.Lines(loaded["Deployment"][name])
+		c.Wasters = wasters(c.Deployments, topN)
+		d.Clusters = append(d.Clusters, c)
+	}
+	sort.Slice(d.Clusters, func(i, j int) bool { return d.Clusters[i].Name < d.Clusters[j].Name })
+	return d, nil
+}
+
+// daily sums the Cluster rows, including the idle row, by Window.
+func daily(allocations []allocation.Allocation) (days []report.Day, total, efficiency float64) {
+	byStart := map[string]*report.Day{}
+	for _, a := range allocations {
+		day, ok := byStart[a.WindowStart]
+		if !ok {
+			start, _ := time.Parse(time.RFC3339, a.WindowStart)
+			day = &report.Day{Start: start}
+			byStart[a.WindowStart] = day
+		}
+		day.Cost += a.TotalCost
+		day.Efficiency += a.TotalCost * a.TotalEfficiency
+		total += a.TotalCost
+		efficiency += a.TotalCost * a.TotalEfficiency
+	}
+	for _, day := range byStart {
+		if day.Cost > 0 {
+			day.Efficiency /= day.Cost
+		}
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Start.Before(days[j].Start) })
+	if total > 0 {
+		efficiency /= total
+	}
+	return days, total, efficiency
+}
+
+func wasters(lines []report.Line, topN int) []report.Line {
+	var w []report.Line
+	for _, l := range lines {
+		if l.Waste > 0 {
+			w = append(w, l)
+		}
+	}
+	sort.SliceStable(w, func(i, j int) bool { return w[i].Waste > w[j].Waste })
+	if len(w) > topN {
+		w = w[:topN]
+	}
+	return w
+}
@@ -0,0 +1,92 @@
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"kubecost-efficiency-fetcher/report"
+	"strings"
+	"time"
+)
+
+// Render returns the dashboard as one HTML page. Styles, script and charts are
+// inline, so the page can be opened from disk or served as a static object.
+func Render(d *Dashboard, title, currency string) ([]byte, error) {
+	funcs := template.FuncMap{
+		"money":   func(value float64) string { return fmt.Sprintf("%s%.2f", currency, value) },
+		"percent": func(value float64) string { return fmt.Sprintf("%.1f%%", value) },
+		"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+		"lastDay": func(t time.Time) string { return t.AddDate(0, 0, -1).Format("2006-01-02") },
+		"chart":   func(days []report.Day) template.HTML { return chart(days, currency) },
+	}
+	t, err := template.New("dashboard").Funcs(funcs).Parse(pageTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct {
+		Title string
+		*Dashboard
+	}{title, d})
+	return buf.Bytes(), err
+}
+
+const (
+	chartWidth  = 720
+	chartHeight = 220
+	chartLeft   = 70 // room for the cost labels
+	chartBottom = 30 // room for the day labels
+)
+
+// chart draws the daily cost as an SVG line chart with the efficiency of each day
+// in the point tooltips.
+func chart(days []report.Day, currency string) template.HTML {
+	if len(days) == 0 {
+		return template.HTML("<p>No Cluster data in this period.</p>")
+	}
+
+	var max float64
+	for _, d := range days {
+		if d.Cost > max {
+			max = d.Cost
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	plotWidth := float64(chartWidth - chartLeft - 10)
+	plotHeight := float64(chartHeight - chartBottom - 10)
+	x := func(i int) float64 {
+		if len(days) == 1 {
+			return chartLeft + plotWidth/2
+		}
+		return chartLeft + plotWidth*float64(i)/float64(len(days)-1)
+	}
+	y := func(cost float64) float64 { return 10 + plotHeight*(1-cost/max) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img">`, chartWidth, chartHeight)
+	for i := 0; i <= 4; i++ {
+		cost := max * float64(i) / 4
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, chartLeft, y(cost), chartWidth-10, y(cost))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="axis" text-anchor="end">%s%.2f</text>`, chartLeft-6, y(cost)+4, template.HTMLEscapeString(currency), cost)
+	}
+
+	points := make([]string, len(days))
+	for i, d := range days {
+		points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(d.Cost))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" class="line"/>`, strings.Join(points, " "))
+
+	// Label about eight days so the labels of long periods do not overlap.
+	step := (len(days) + 7) / 8
+	for i, d := range days {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" class="point"><title>%s: %s%.2f, %.1f%% efficient</title></circle>`,
+			x(i), y(d.Cost), d.Start.Format("2006-01-02"), template.HTMLEscapeString(currency), d.Cost, d.Efficiency)
+		if i%step == 0 || i == len(days)-1 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis" text-anchor="middle">%s</text>`, x(i), chartHeight-10, d.Start.Format("01-02"))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,98 @@
+package dashboard
+
+const pageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Arial, Helvetica, sans-serif; color: #222; margin: 24px auto; max-width: 980px; padding: 0 16px; }
+h1 { margin-bottom: 4px; }
+h2 { border-top: 1px solid #ddd; padding-top: 16px; }
+.muted { color: #666; }
+.totals span { display: inline-block; margin-right: 32px; }
+.totals b { font-size: 20px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 16px; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #eee; }
+th { background: #f0f0f0; text-align: left; }
+th.sortable { cursor: pointer; user-select: none; }
+th.sortable:after { content: " \2195"; color: #aaa; }
+.num { text-align: right; }
+.chart { width: 100%; height: auto; }
+.chart .grid { stroke: #eee; }
+.chart .axis { font-size: 11px; fill: #666; }
+.chart .line { fill: none; stroke: #2f6fdb; stroke-width: 2; }
+.chart .point { fill: #2f6fdb; }
+details { margin-bottom: 16px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{if .Start.IsZero}}All collected history{{else}}{{date .Start}} to {{lastDay .End}}{{end}}. Generated {{.Generated.Format "2006-01-02 15:04 UTC"}}.</p>
+{{- range .Clusters}}
+<h2>Cluster {{.Name}}</h2>
+<p class="totals"><span>Total cost <b>{{money .TotalCost}}</b></span><span>Efficiency <b>{{percent .Efficiency}}</b></span></p>
+<h3>Cost over time</h3>
+{{chart .Daily}}
+<h3>Top wasters</h3>
+{{- if .Wasters}}
+<table class="sortable">
+<thead><tr><th class="sortable">Deployment</th><th class="sortable">Namespace</th><th class="sortable num">Cost</th><th class="sortable num">Efficiency</th><th class="sortable num">Waste</th></tr></thead>
+<tbody>
+{{- range .Wasters}}
+<tr><td>{{.Name}}</td><td>{{.Namespace}}</td><td class="num" data-value="{{.Cost}}">{{money .Cost}}</td><td class="num" data-value="{{.Efficiency}}">{{percent .Efficiency}}</td><td class="num" data-value="{{.Waste}}">{{money .Waste}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- else}}
+<p>No deployments.</p>
+{{- end}}
+<h3>Namespaces</h3>
+<table class="sortable">
+<thead><tr><th class="sortable">Namespace</th><th class="sortable num">Cost</th><th class="sortable num">Efficiency</th></tr></thead>
+<tbody>
+{{- range .Namespaces}}
+<tr><td>{{.Name}}</td><td class="num" data-value="{{.Cost}}">{{money .Cost}}</td><td class="num" data-value="{{.Efficiency}}">{{percent .Efficiency}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<details>
+<summary>Deployments ({{len .Deployments}})</summary>
+<table class="sortable">
+<thead><tr><th class="sortable">Deployment</th><th class="sortable">Namespace</th><th class="sortable num">Cost</th><th class="sortable num">Efficiency</th></tr></thead>
+<tbody>
+{{- range .Deployments}}
+<tr><td>{{.Name}}</td><td>{{.Namespace}}</td><td class="num" data-value="{{.Cost}}">{{money .Cost}}</td><td class="num" data-value="{{.Efficiency}}">{{percent .Efficiency}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</details>
+{{- else}}
+<p>No Cluster data in this period.</p>
+{{- end}}
+<p class="muted">Efficiencies are cost-weighted averages over the period. Waste is the CPU and RAM cost multiplied by (1 - CPU and RAM efficiency). Generated by kubecost-efficiency-fetcher.</p>
+<script>
+// Sort a table by the clicked column; numeric cells carry their value in data-value.
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th.sortable").forEach(function (th, column) {
+    var ascending = false;
+    th.addEventListener("click", function () {
+      ascending = !ascending;
+      var tbody = table.tBodies[0];
+      var rows = Array.prototype.slice.call(tbody.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column], y = b.cells[column];
+        var result = x.dataset.value !== undefined
+          ? parseFloat(x.dataset.value) - parseFloat(y.dataset.value)
+          : x.textContent.localeCompare(y.textContent);
+        return ascending ? result : -result;
+      });
+      rows.forEach(function (row) { tbody.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`
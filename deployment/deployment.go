@@ -1,22 +1,23 @@
 package deployment
 
 import (
-	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"kubecost-efficiency-fetcher/allocation"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"time"
-	"os"
 )
 
-func FetchAndWriteDeploymentData(inputURL, clusterName, window, bucketName, region string, wg *sync.WaitGroup) {
+func FetchAndWriteDeploymentData(inputURL, clusterName, window, region string, store storage.Backend, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 	u, err := url.Parse(inputURL)
@@ -66,58 +67,13 @@ func FetchAndWriteDeploymentData(inputURL, clusterName, window, bucketName, regi
 	data := result["data"].([]interface{})
 
 
-	svc := configs.Svc
-
-	objectKey := "Deployment/Deployment.csv"
-
-	
-	existingData := [][]string{}
-	fileExists := false
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err == nil {
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		reader := csv.NewReader(resp.Body)
-		existingData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing CSV data:", err)
-			return
-		}
-		fileExists = true
-	} else {
-		configs.InfoLogger.Println("No existing Deployment.csv file found. A new one will be created.")
-	}
-
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
-	defer writer.Flush()
+	objectKey := configs.ObjectKey("Deployment")
 
 	
-	if !fileExists {
-		header := []string{
-			"Deployment", "ClusterName", "Region", "Namespace",
-			"Window Start", "Window End", "Cpu Cost", "Gpu Cost",
-			"Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost",
-			"Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency",
-		}
-		if err := writer.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to CSV:", err)
-			return
-		}
-	}
+	records := [][]string{}
+	allocations := []allocation.Allocation{}
+	var missing []string
 
-	
 	for _, element := range data {
 		if element == nil{
 			configs.InfoLogger.Println("No Data for Deployment")
@@ -169,6 +125,9 @@ func FetchAndWriteDeploymentData(inputURL, clusterName, window, bucketName, regi
 			cpuEfficiency := deploymentOne["cpuEfficiency"].(float64) * 100
 			ramEfficiency := deploymentOne["ramEfficiency"].(float64) * 100
 			totalEfficiency := deploymentOne["totalEfficiency"].(float64) * 100
+			cpuWaste := allocation.Waste(cpuCost, cpuEfficiency)
+			ramWaste := allocation.Waste(ramCost, ramEfficiency)
+			cpuCoreRequestAverage, cpuCoreUsageAverage, ramBytesRequestAverage, ramBytesUsageAverage := allocation.Averages(deploymentOne, &missing)
 
 			record := []string{
 				name, clusterName, region, namespaceDeployment, windowStart, windowEnd,
@@ -178,40 +137,61 @@ func FetchAndWriteDeploymentData(inputURL, clusterName, window, bucketName, regi
 				fmt.Sprintf("%f", totalCost),
 				fmt.Sprintf("%f", cpuEfficiency), fmt.Sprintf("%f", ramEfficiency),
 				fmt.Sprintf("%f", totalEfficiency),
+				fmt.Sprintf("%f", cpuCoreRequestAverage), fmt.Sprintf("%f", cpuCoreUsageAverage),
+				fmt.Sprintf("%f", ramBytesRequestAverage), fmt.Sprintf("%f", ramBytesUsageAverage),
+				fmt.Sprintf("%f", cpuWaste), fmt.Sprintf("%f", ramWaste),
+			}
+			records = append(records, record)
+
+			alloc := allocation.Allocation{
+				Aggregation:      "Deployment",
+				Name:             name,
+				Cluster:          clusterName,
+				Region:           region,
+				Namespace:        namespaceDeployment,
+				WindowStart:      windowStart,
+				WindowEnd:        windowEnd,
+				CpuCost:          cpuCost,
+				GpuCost:          gpuCost,
+				RamCost:          ramCost,
+				PVCost:           pvCost,
+				NetworkCost:      networkCost,
+				LoadBalancerCost: loadBalancerCost,
+				TotalCost:        totalCost,
+				CpuEfficiency:    cpuEfficiency,
+				RamEfficiency:    ramEfficiency,
+				TotalEfficiency:  totalEfficiency,
+				CpuWaste:         cpuWaste,
+				RamWaste:         ramWaste,
+				Properties:       properties,
+
+				CpuCoreRequestAverage:  cpuCoreRequestAverage,
+				CpuCoreUsageAverage:    cpuCoreUsageAverage,
+				RamBytesRequestAverage: ramBytesRequestAverage,
+				RamBytesUsageAverage:   ramBytesUsageAverage,
 			}
-			existingData = append(existingData, record)
+			allocations = append(allocations, alloc)
 		}
 	}
-
-	
-	if err := writer.WriteAll(existingData); err != nil {
-		configs.ErrorLogger.Println("Error writing data to CSV:", err)
-		return
+	if len(missing) > 0 {
+		configs.ErrorLogger.Printf("Deployment data without %s, rightsizing reads them as 0\n", strings.Join(missing, ", "))
 	}
 
-	err = os.MkdirAll("Output", 0755)
+	
+	content, err := history.Append(store, objectKey, schema.Deployment, records)
 	if err != nil {
-		configs.ErrorLogger.Println("Error creating directory:", err)
+		configs.ErrorLogger.Println("Error uploading updated CSV to storage:", err)
 		return
 	}
 
-	err = os.WriteFile("Output/Deployment.csv", buffer.Bytes(), 0644)
+	err = storage.WriteLocalCopy("Deployment.csv", content)
 	if err != nil {
 		configs.ErrorLogger.Println("Error saving file deployment.csv:", err)
 		return
 	}
 
 	
-	_, err = svc.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectKey),
-		Body:        bytes.NewReader(buffer.Bytes()),
-		ContentType: aws.String("text/csv"),
-	})
-	if err != nil {
-		configs.ErrorLogger.Println("Error uploading updated CSV to S3:", err)
-		return
-	}
+	sink.Publish("Deployment", allocations)
 
-	configs.InfoLogger.Println("Deployment data successfully written to S3")
+	configs.InfoLogger.Println("Deployment data successfully written to", store)
 }
@@ -0,0 +1,166 @@
+package digest
+
+import (
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/storage"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Entry is one line of a top list.
+type Entry struct {
+	Name       string
+	Namespace  string
+	Cost       float64
+	Efficiency float64 // percent
+}
+
+// Change is the day-over-day cost change of a namespace or deployment.
+type Change struct {
+	Aggregation string // "Namespace" or "Deployment"
+	Name        string
+	Namespace   string
+	Previous    float64
+	Current     float64
+}
+
+// Delta returns the change in cost.
+func (c Change) Delta() float64 {
+	return c.Current - c.Previous
+}
+
+// Digest summarises one Window of a cluster, or of a single namespace.
+type Digest struct {
+	Cluster   string
+	Namespace string // set for the digest of a namespace route
+	Window    string
+	RunID     string
+
+	TotalCost         float64
+	PreviousTotalCost float64 // 0 when the previous day was not collected
+	Efficiency        float64 // percent
+
+	TopNamespaces  []Entry
+	TopDeployments []Entry
+	Changes        []Change
+}
+
+// Build creates the digest of window from the stored history of cluster: the
+// total cost and efficiency from the Cluster rows, the topN namespaces and
+// deployments by cost, and the topN biggest day-over-day changes. With namespace
+// set, the digest only covers that namespace and its totals come from its
+// Namespace row.
+func Build(store storage.Backend, cluster, window, runID, namespace string, topN int) (*Digest, error) {
+	start, _, _ := strings.Cut(window, ",")
+	d := &Digest{Cluster: cluster, Namespace: namespace, Window: window, RunID: runID}
+
+	loaded := map[string][]allocation.Allocation{}
+	for _, aggregation := range []string{"Cluster", "Namespace", "Deployment"} {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		// The history of a shared store also holds the rows of other clusters.
+		loaded[aggregation] = filter(allocations, func(a allocation.Allocation) bool { return a.Cluster == cluster })
+	}
+
+	// Rows of the previous day end where the current Window starts.
+	current := func(a allocation.Allocation) bool { return a.WindowStart == start }
+	previous := func(a allocation.Allocation) bool { return a.WindowEnd == start }
+	inScope := func(a allocation.Allocation) bool { return namespace == "" || a.Namespace == namespace }
+
+	totals := loaded["Cluster"]
+	if namespace != "" {
+		totals = loaded["Namespace"]
+	}
+	var weighted float64
+	for _, a := range totals {
+		if !inScope(a) {
+			continue
+		}
+		if current(a) {
+			d.TotalCost += a.TotalCost
+			weighted += a.TotalCost * a.TotalEfficiency
+		} else if previous(a) {
+			d.PreviousTotalCost += a.TotalCost
+		}
+	}
+	if d.TotalCost > 0 {
+		d.Efficiency = weighted / d.TotalCost
+	}
+
+	if namespace == "" {
+		d.TopNamespaces = top(loaded["Namespace"], current, topN)
+	}
+	d.TopDeployments = top(filter(loaded["Deployment"], inScope), current, topN)
+
+	for _, aggregation := range []string{"Namespace", "Deployment"} {
+		d.Changes = append(d.Changes, changes(aggregation, filter(loaded[aggregation], inScope), current, previous)...)
+	}
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		return math.Abs(d.Changes[i].Delta()) > math.Abs(d.Changes[j].Delta())
+	})
+	if len(d.Changes) > topN {
+		d.Changes = d.Changes[:topN]
+	}
+	return d, nil
+}
+
+func filter(allocations []allocation.Allocation, keep func(allocation.Allocation) bool) []allocation.Allocation {
+	var kept []allocation.Allocation
+	for _, a := range allocations {
+		if keep(a) {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// top returns the n most expensive allocations of the current Window.
+func top(allocations []allocation.Allocation, current func(allocation.Allocation) bool, n int) []Entry {
+	var entries []Entry
+	for _, a := range allocations {
+		if current(a) {
+			entries = append(entries, Entry{Name: a.Name, Namespace: a.Namespace, Cost: a.TotalCost, Efficiency: a.TotalEfficiency})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Cost > entries[j].Cost })
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// changes compares the current and the previous day by name and namespace. Names
+// only present on one day count as a change from or to zero.
+func changes(aggregation string, allocations []allocation.Allocation, current, previous func(allocation.Allocation) bool) []Change {
+	byName := map[[2]string]*Change{}
+	for _, a := range allocations {
+		isCurrent, isPrevious := current(a), previous(a)
+		if !isCurrent && !isPrevious {
+			continue
+		}
+		key := [2]string{a.Namespace, a.Name}
+		c, ok := byName[key]
+		if !ok {
+			c = &Change{Aggregation: aggregation, Name: a.Name, Namespace: a.Namespace}
+			byName[key] = c
+		}
+		if isCurrent {
+			c.Current += a.TotalCost
+		} else {
+			c.Previous += a.TotalCost
+		}
+	}
+
+	var result []Change
+	for _, c := range byName {
+		if c.Delta() != 0 {
+			result = append(result, *c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,196 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Templates are the configurable texts of a digest. Both are text/template strings
+// executed with the Digest, and can use the money, percent and change functions.
+type Templates struct {
+	Title   string
+	Summary string
+}
+
+func money(currency string, value float64) string {
+	if value < 0 {
+		return fmt.Sprintf("-%s%.2f", currency, -value)
+	}
+	return fmt.Sprintf("%s%.2f", currency, value)
+}
+
+// change formats the difference to a previous value, e.g. "+$1.20 (+8.0%)".
+func change(currency string, current, previous float64) string {
+	delta := current - previous
+	sign := "+"
+	if delta < 0 {
+		sign = ""
+	}
+	if previous == 0 {
+		return sign + money(currency, delta)
+	}
+	return fmt.Sprintf("%s%s (%s%.1f%%)", sign, money(currency, delta), sign, delta/previous*100)
+}
+
+// Funcs are the functions available in the templates.
+func Funcs(currency string) template.FuncMap {
+	return template.FuncMap{
+		"money":   func(value float64) string { return money(currency, value) },
+		"percent": func(value float64) string { return fmt.Sprintf("%.1f%%", value) },
+		"change":  func(current, previous float64) string { return change(currency, current, previous) },
+	}
+}
+
+type renderer struct {
+	d        *Digest
+	currency string
+	title    string
+	summary  string
+}
+
+func newRenderer(d *Digest, templates Templates, currency string) (*renderer, error) {
+	r := &renderer{d: d, currency: currency}
+	var err error
+	if r.title, err = r.execute("title", templates.Title); err != nil {
+		return nil, err
+	}
+	if r.summary, err = r.execute("summary", templates.Summary); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *renderer) execute(name, text string) (string, error) {
+	t, err := template.New(name).Funcs(Funcs(r.currency)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, r.d); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func entryName(e Entry, withNamespace bool) string {
+	if withNamespace && e.Namespace != "" {
+		return e.Namespace + "/" + e.Name
+	}
+	return e.Name
+}
+
+func changeName(c Change) string {
+	if c.Aggregation == "Deployment" && c.Namespace != "" {
+		return c.Namespace + "/" + c.Name
+	}
+	return c.Name
+}
+
+// section is a titled list rendered by both formats.
+type section struct {
+	title string
+	lines [][2]string // name, value
+}
+
+func (r *renderer) sections() []section {
+	var sections []section
+	add := func(title string, entries []Entry, withNamespace bool) {
+		if len(entries) == 0 {
+			return
+		}
+		s := section{title: title}
+		for _, e := range entries {
+			s.lines = append(s.lines, [2]string{entryName(e, withNamespace), fmt.Sprintf("%s, %.1f%% efficient", money(r.currency, e.Cost), e.Efficiency)})
+		}
+		sections = append(sections, s)
+	}
+	add("Top namespaces", r.d.TopNamespaces, false)
+	add("Top deployments", r.d.TopDeployments, true)
+
+	if len(r.d.Changes) > 0 {
+		s := section{title: "Biggest changes since the previous day"}
+		for _, c := range r.d.Changes {
+			s.lines = append(s.lines, [2]string{changeName(c), change(r.currency, c.Current, c.Previous)})
+		}
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+func (r *renderer) footer() string {
+	return fmt.Sprintf("Window %s, run %s", r.d.Window, r.d.RunID)
+}
+
+// Slack renders the digest as a Slack incoming webhook message using Block Kit.
+func Slack(d *Digest, templates Templates, currency string) ([]byte, error) {
+	r, err := newRenderer(d, templates, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	text := func(kind, value string) map[string]interface{} {
+		return map[string]interface{}{"type": kind, "text": value}
+	}
+	blocks := []interface{}{
+		map[string]interface{}{"type": "header", "text": text("plain_text", r.title)},
+		map[string]interface{}{"type": "section", "text": text("mrkdwn", r.summary)},
+	}
+	for _, s := range r.sections() {
+		var lines []string
+		for i, line := range s.lines {
+			lines = append(lines, fmt.Sprintf("%d. `%s` %s", i+1, line[0], line[1]))
+		}
+		blocks = append(blocks,
+			map[string]interface{}{"type": "divider"},
+			map[string]interface{}{"type": "section", "text": text("mrkdwn", "*"+s.title+"*\n"+strings.Join(lines, "\n"))},
+		)
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "context",
+		"elements": []interface{}{text("mrkdwn", r.footer())},
+	})
+
+	return json.Marshal(map[string]interface{}{"text": r.title, "blocks": blocks})
+}
+
+// Teams renders the digest as a Microsoft Teams incoming webhook message carrying
+// an Adaptive Card.
+func Teams(d *Digest, templates Templates, currency string) ([]byte, error) {
+	r, err := newRenderer(d, templates, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": r.title, "size": "Large", "weight": "Bolder", "wrap": true},
+		map[string]interface{}{"type": "TextBlock", "text": r.summary, "wrap": true},
+	}
+	for _, s := range r.sections() {
+		var facts []interface{}
+		for i, line := range s.lines {
+			facts = append(facts, map[string]interface{}{"title": fmt.Sprintf("%d. %s", i+1, line[0]), "value": line[1]})
+		}
+		body = append(body,
+			map[string]interface{}{"type": "TextBlock", "text": s.title, "weight": "Bolder", "separator": true, "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		)
+	}
+	body = append(body, map[string]interface{}{"type": "TextBlock", "text": r.footer(), "isSubtle": true, "size": "Small", "wrap": true})
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	return json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	})
+}
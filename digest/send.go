@@ -0,0 +1,67 @@
+package digest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"kubecost-efficiency-fetcher/configs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Render returns the webhook message of the digest in the format of the channel.
+func Render(d *Digest, format string, templates Templates, currency string) ([]byte, error) {
+	switch format {
+	case "slack":
+		return Slack(d, templates, currency)
+	case "teams":
+		return Teams(d, templates, currency)
+	}
+	return nil, fmt.Errorf("unknown digest format %q, use slack or teams", format)
+}
+
+// Post sends a rendered message to an incoming webhook, retrying failed requests.
+func Post(url string, body []byte) error {
+	var err error
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if err = post(url, body); err == nil {
+			return nil
+		}
+		configs.ErrorLogger.Printf("Attempt %d: Error posting digest: %v\n", attempt, err)
+		time.Sleep(2 * time.Second)
+	}
+	return err
+}
+
+func post(url string, body []byte) error {
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Send renders the digest for every channel and posts it. A failing channel does
+// not keep the digest from the others.
+func Send(d *Digest, channels []configs.DigestChannel, templates Templates, currency string) error {
+	var errs []error
+	for _, channel := range channels {
+		body, err := Render(d, channel.Format, templates, currency)
+		if err == nil {
+			err = Post(channel.URL, body)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("posting %s digest: %w", channel.Format, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,206 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"kubecost-efficiency-fetcher/report"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Section is the part of a report about one namespace.
+type Section struct {
+	Namespace   report.Line
+	Deployments []report.Line
+}
+
+// Report is the content of one email. Overview adds the cluster totals; team
+// emails only carry the sections of their namespaces.
+type Report struct {
+	Title    string
+	Overview bool
+	Summary  *report.Summary
+	Sections []Section
+}
+
+// Message is a rendered email.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+func funcs(currency string) map[string]interface{} {
+	return map[string]interface{}{
+		"money":   func(value float64) string { return fmt.Sprintf("%s%.2f", currency, value) },
+		"percent": func(value float64) string { return fmt.Sprintf("%.1f%%", value) },
+		"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+		// lastDay formats the day before an exclusive period end.
+		"lastDay": func(t time.Time) string { return t.AddDate(0, 0, -1).Format("2006-01-02") },
+	}
+}
+
+// Sections returns the sections of the namespaces accepted by keep, each with its
+// topN deployments.
+func Sections(s *report.Summary, keep func(namespace string) bool, topN int) []Section {
+	var sections []Section
+	for _, namespace := range s.Namespaces {
+		if !keep(namespace.Name) {
+			continue
+		}
+		deployments := s.DeploymentsOf(namespace.Name)
+		if len(deployments) > topN {
+			deployments = deployments[:topN]
+		}
+		sections = append(sections, Section{Namespace: namespace, Deployments: deployments})
+	}
+	return sections
+}
+
+// Plan returns the emails of a summary: the full report for recipients, and for
+// every address in namespaceRecipients or labelRecipients a report with the
+// sections of the namespaces it is routed to. Keys of namespaceRecipients are
+// namespace names or path.Match patterns such as "team-a-*". Keys of
+// labelRecipients are "key=value" pod labels; a namespace is routed when labels
+// records the label on one of its pods during the summarised period.
+func Plan(s *report.Summary, recipients []string, namespaceRecipients, labelRecipients map[string][]string, labels Labels, topN int) map[string]Report {
+	title := fmt.Sprintf("Kubecost cost report: %s", s.Cluster)
+	reports := map[string]Report{}
+	for _, to := range recipients {
+		reports[to] = Report{Title: title, Overview: true, Summary: s, Sections: Sections(s, func(string) bool { return true }, topN)}
+	}
+
+	since := s.Start.Format(time.RFC3339)
+	routed := map[string][]func(namespace string) bool{}
+	for pattern, addresses := range namespaceRecipients {
+		for _, to := range addresses {
+			routed[to] = append(routed[to], func(namespace string) bool {
+				ok, _ := path.Match(pattern, namespace)
+				return ok
+			})
+		}
+	}
+	for label, addresses := range labelRecipients {
+		for _, to := range addresses {
+			routed[to] = append(routed[to], func(namespace string) bool {
+				return labels.Matches(namespace, label, since)
+			})
+		}
+	}
+	for to, routes := range routed {
+		if _, ok := reports[to]; ok {
+			continue // already receives the full report
+		}
+		match := func(namespace string) bool {
+			for _, route := range routes {
+				if route(namespace) {
+					return true
+				}
+			}
+			return false
+		}
+		sections := Sections(s, match, topN)
+		if len(sections) == 0 {
+			continue
+		}
+		var names []string
+		for _, section := range sections {
+			names = append(names, section.Namespace.Name)
+		}
+		reports[to] = Report{Title: title + " / " + strings.Join(names, ", "), Summary: s, Sections: sections}
+	}
+	return reports
+}
+
+// Render renders a report as an email to the given address.
+func Render(to string, r Report, currency string) (*Message, error) {
+	var text, html bytes.Buffer
+	t, err := texttemplate.New("text").Funcs(funcs(currency)).Parse(textTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(&text, r); err != nil {
+		return nil, err
+	}
+	h, err := htmltemplate.New("html").Funcs(funcs(currency)).Parse(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Execute(&html, r); err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf("%s (%s to %s)", r.Title, r.Summary.Start.Format("2006-01-02"), r.Summary.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	return &Message{To: []string{to}, Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
+
+// Bytes returns the message in RFC 5322 format, as multipart/alternative with a
+// plain-text and an HTML part.
+func (m *Message) Bytes(from string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		domain = address.Address[strings.LastIndex(address.Address, "@")+1:]
+	}
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           strings.Join(m.To, ", "),
+		"Subject":      mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain),
+		"MIME-Version": "1.0",
+		"Content-Type": fmt.Sprintf("multipart/alternative; boundary=%q", writer.Boundary()),
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var message bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&message, "%s: %s\r\n", name, headers[name])
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
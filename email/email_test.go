@@ -0,0 +1,166 @@
+package email
+
+import (
+	"bufio"
+	"kubecost-efficiency-fetcher/report"
+	"net"
+	"net/mail"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func summary() *report.Summary {
+	return &report.Summary{
+		Cluster: "prod",
+		Start:   time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2024, 10, 8, 0, 0, 0, 0, time.UTC),
+		Namespaces: []report.Line{
+			{Name: "team-a-api", Cost: 30},
+			{Name: "team-a-jobs", Cost: 20},
+			{Name: "team-b", Cost: 10},
+		},
+		Deployments: []report.Line{
+			{Name: "api", Namespace: "team-a-api", Cost: 20},
+			{Name: "gateway", Namespace: "team-a-api", Cost: 10},
+			{Name: "worker", Namespace: "team-b", Cost: 10},
+		},
+	}
+}
+
+func namespacesOf(r Report) []string {
+	var names []string
+	for _, section := range r.Sections {
+		names = append(names, section.Namespace.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPlan(t *testing.T) {
+	labels := Labels{
+		"team-b":      {"team=b": "2024-10-03T00:00:00Z"},
+		"team-a-jobs": {"team=b": "2024-09-20T00:00:00Z"}, // before the period
+	}
+	reports := Plan(summary(),
+		[]string{"finops@example.com"},
+		map[string][]string{"team-a-*": {"a@example.com"}, "team-c": {"c@example.com"}},
+		map[string][]string{"team=b": {"b@example.com", "finops@example.com"}},
+		labels, 1)
+
+	tests := []struct {
+		to         string
+		overview   bool
+		namespaces []string
+	}{
+		{"finops@example.com", true, []string{"team-a-api", "team-a-jobs", "team-b"}},
+		{"a@example.com", false, []string{"team-a-api", "team-a-jobs"}},
+		{"b@example.com", false, []string{"team-b"}},
+	}
+	if len(reports) != len(tests) {
+		t.Errorf("got %d reports, want %d", len(reports), len(tests))
+	}
+	for _, tt := range tests {
+		r, ok := reports[tt.to]
+		if !ok {
+			t.Errorf("no report for %s", tt.to)
+			continue
+		}
+		if r.Overview != tt.overview || strings.Join(namespacesOf(r), ",") != strings.Join(tt.namespaces, ",") {
+			t.Errorf("%s: overview %t, namespaces %q", tt.to, r.Overview, namespacesOf(r))
+		}
+		for _, section := range r.Sections {
+			if len(section.Deployments) > 1 {
+				t.Errorf("%s: %d deployments of %s, want the top 1", tt.to, len(section.Deployments), section.Namespace.Name)
+			}
+		}
+	}
+}
+
+// serveSMTP accepts one session on l and sends the commands and the message lines
+// it received to done.
+func serveSMTP(t *testing.T, l net.Listener, done chan<- []string) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		close(done)
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var received []string
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		received = append(received, line)
+		switch {
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(line, "AUTH PLAIN"):
+			reply("235 Authentication successful")
+		case line == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			for {
+				data, err := reader.ReadString('\n')
+				if err != nil || data == ".\r\n" {
+					break
+				}
+				received = append(received, strings.TrimRight(data, "\r\n"))
+			}
+			reply("250 OK")
+		case line == "QUIT":
+			reply("221 Bye")
+			done <- received
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+	done <- received
+}
+
+func TestSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := make(chan []string, 1)
+	go serveSMTP(t, l, done)
+
+	message, err := Render("a@example.com", Report{Title: "Kubecost cost report: prod", Overview: true, Summary: summary()}, "$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	server := SMTP{Host: "localhost", Port: port, Username: "user", Password: "password", From: "Kubecost <kubecost@example.com>"}
+	if err := server.Send(message); err != nil {
+		t.Fatal(err)
+	}
+
+	received := strings.Join(<-done, "\n")
+	for _, want := range []string{"AUTH PLAIN", "MAIL FROM:<kubecost@example.com>", "RCPT TO:<a@example.com>", "Subject: Kubecost cost report: prod"} {
+		if !strings.Contains(received, want) {
+			t.Errorf("session has no %q:\n%s", want, received)
+		}
+	}
+	_, data, ok := strings.Cut(received, "\nDATA\n")
+	if !ok {
+		t.Fatal("no message")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(strings.ReplaceAll(data, "\n", "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Errorf("content type %s", msg.Header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,112 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/storage"
+	"sync"
+)
+
+// Labels records, for every namespace, the pod labels seen in it as "key=value"
+// and the start of the last Window they were seen in.
+type Labels map[string]map[string]string
+
+// Matches reports whether a pod of namespace carried label in a Window starting
+// at or after since.
+func (l Labels) Matches(namespace, label, since string) bool {
+	seen, ok := l[namespace][label]
+	return ok && seen >= since
+}
+
+// LabelSink records the labels of the collected pods. Labels are not kept in the
+// CSV history, so label routes only cover the runs they were recorded in.
+type LabelSink struct {
+	windowStart string
+
+	mu     sync.Mutex
+	labels Labels
+}
+
+func NewLabelSink(windowStart string) *LabelSink {
+	return &LabelSink{windowStart: windowStart, labels: Labels{}}
+}
+
+func (s *LabelSink) Name() string {
+	return "email labels"
+}
+
+func (s *LabelSink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if aggregation != "Pod" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range allocations {
+		labels, _ := a.Properties["labels"].(map[string]interface{})
+		for key, value := range labels {
+			v, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if s.labels[a.Namespace] == nil {
+				s.labels[a.Namespace] = map[string]string{}
+			}
+			s.labels[a.Namespace][key+"="+v] = s.windowStart
+		}
+	}
+	return nil
+}
+
+func (s *LabelSink) Close() error {
+	return nil
+}
+
+// Save merges the recorded labels into the labels stored at key. A label seen
+// again keeps the later of the two Window starts.
+func (s *LabelSink) Save(store storage.Backend, key string) error {
+	stored, version, err := readLabels(store, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for namespace, labels := range s.labels {
+		if stored[namespace] == nil {
+			stored[namespace] = map[string]string{}
+		}
+		for label, seen := range labels {
+			if seen > stored[namespace][label] {
+				stored[namespace][label] = seen
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteIf(key, data, "application/json", version)
+}
+
+// LoadLabels reads the labels stored at key. Nothing stored yet is not an error.
+func LoadLabels(store storage.Backend, key string) (Labels, error) {
+	labels, _, err := readLabels(store, key)
+	return labels, err
+}
+
+func readLabels(store storage.Backend, key string) (Labels, string, error) {
+	labels := Labels{}
+	object, err := store.Read(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return labels, "", nil
+	}
+	if err != nil {
+		return labels, "", err
+	}
+	if err := json.Unmarshal(object.Data, &labels); err != nil {
+		return labels, "", fmt.Errorf("%s: %w", key, err)
+	}
+	return labels, object.Version, nil
+}
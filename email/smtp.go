@@ -0,0 +1,74 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP holds the settings of the mail server.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string // PLAIN auth, used when set
+	Password string
+	StartTLS bool // upgrade the connection with STARTTLS before authenticating
+	From     string
+}
+
+// Send delivers the message through the server.
+func (s SMTP) Send(m *Message) error {
+	data, err := m.Bytes(s.From)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.Dial(net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if s.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", s.Host)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection,
+		// except to localhost.
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("sender address: %w", err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
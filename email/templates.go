@@ -0,0 +1,66 @@
+package email
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, Helvetica, sans-serif; color: #222; max-width: 720px;">
+<h2 style="margin-bottom: 4px;">{{.Title}}</h2>
+<p style="color: #666; margin-top: 0;">{{date .Summary.Start}} to {{lastDay .Summary.End}}</p>
+{{- if .Overview}}
+<table cellpadding="6" style="border-collapse: collapse; margin-bottom: 16px;">
+<tr><td><b>Total cost</b></td><td>{{money .Summary.TotalCost}}</td></tr>
+<tr><td><b>Efficiency</b></td><td>{{percent .Summary.Efficiency}}</td></tr>
+</table>
+{{- if .Summary.Daily}}
+<h3>Daily cost</h3>
+<table cellpadding="6" style="border-collapse: collapse;">
+<tr style="background: #f0f0f0;"><th align="left">Day</th><th align="right">Cost</th><th align="right">Efficiency</th></tr>
+{{- range .Summary.Daily}}
+<tr><td>{{date .Start}}</td><td align="right">{{money .Cost}}</td><td align="right">{{percent .Efficiency}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- range .Sections}}
+<h3 style="border-top: 1px solid #ddd; padding-top: 12px;">Namespace {{.Namespace.Name}}: {{money .Namespace.Cost}}, {{percent .Namespace.Efficiency}} efficient</h3>
+{{- if .Deployments}}
+<table cellpadding="6" style="border-collapse: collapse;">
+<tr style="background: #f0f0f0;"><th align="left">Deployment</th><th align="right">Cost</th><th align="right">Efficiency</th></tr>
+{{- range .Deployments}}
+<tr><td>{{.Name}}</td><td align="right">{{money .Cost}}</td><td align="right">{{percent .Efficiency}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No deployments.</p>
+{{- end}}
+{{- end}}
+<p style="color: #999; font-size: 12px;">Efficiencies are cost-weighted averages over the period. Generated by kubecost-efficiency-fetcher.</p>
+</body>
+</html>
+`
+
+const textTemplate = `{{.Title}}
+{{date .Summary.Start}} to {{lastDay .Summary.End}}
+{{- if .Overview}}
+
+Total cost: {{money .Summary.TotalCost}}
+Efficiency: {{percent .Summary.Efficiency}}
+{{- if .Summary.Daily}}
+
+Daily cost:
+{{- range .Summary.Daily}}
+  {{date .Start}}  {{money .Cost}}  {{percent .Efficiency}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Sections}}
+
+Namespace {{.Namespace.Name}}: {{money .Namespace.Cost}}, {{percent .Namespace.Efficiency}} efficient
+{{- range .Deployments}}
+  {{.Name}}  {{money .Cost}}  {{percent .Efficiency}}
+{{- else}}
+  No deployments.
+{{- end}}
+{{- end}}
+
+Efficiencies are cost-weighted averages over the period.
+`
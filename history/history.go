@@ -0,0 +1,89 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/storage"
+)
+
+// maxAttempts bounds how often Append retries when the object is modified
+// concurrently between reading and writing it.
+const maxAttempts = 3
+
+// Append adds records, laid out according to header, to the CSV object stored
+// at key and returns the content that was written. An object written with a
+// different header is migrated to header first: columns are mapped by name and
+// new columns are left empty for the existing rows.
+func Append(store storage.Backend, key string, header []string, records [][]string) ([]byte, error) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var content []byte
+		content, err = appendOnce(store, key, header, records)
+		if !errors.Is(err, storage.ErrPreconditionFailed) {
+			return content, err
+		}
+		configs.InfoLogger.Printf("Attempt %d: %s was modified concurrently, retrying\n", attempt, key)
+	}
+	return nil, err
+}
+
+func appendOnce(store storage.Backend, key string, header []string, records [][]string) ([]byte, error) {
+	existing := [][]string{header}
+	version := ""
+
+	object, err := store.Read(key)
+	switch {
+	case err == nil:
+		version = object.Version
+		rows, err := Parse(object.Data)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 {
+			existing = Migrate(rows, header)
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		configs.InfoLogger.Printf("No existing %s found. A new one will be created.\n", key)
+	default:
+		return nil, err
+	}
+
+	// Columns only present in the existing object are kept after the current
+	// ones, so the new records are padded to the migrated header.
+	width := len(existing[0])
+	for _, record := range records {
+		for len(record) < width {
+			record = append(record, "")
+		}
+		existing = append(existing, record)
+	}
+
+	content, err := Encode(existing)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.WriteIf(key, content, "text/csv", version); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Parse reads the rows of a CSV object, including its header. Rows may have
+// different lengths, e.g. in objects that were appended to with a changed header.
+func Parse(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// Encode writes rows as CSV.
+func Encode(rows [][]string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
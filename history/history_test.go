@@ -0,0 +1,181 @@
+package history
+
+import (
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"empty", "", nil},
+		{"header only", "Name,Cost\n", [][]string{{"Name", "Cost"}}},
+		{"rows", "Name,Cost\na,1\nb,2\n", [][]string{{"Name", "Cost"}, {"a", "1"}, {"b", "2"}}},
+		{"quoted", "Name,Labels\n\"a,b\",\"x=\"\"y\"\"\"\n", [][]string{{"Name", "Labels"}, {"a,b", `x="y"`}}},
+		{"appended with a changed header", "Name,Cost\na,1\nb,2,3\n", [][]string{{"Name", "Cost"}, {"a", "1"}, {"b", "2", "3"}}},
+		{"windows line endings", "Name,Cost\r\na,1\r\n", [][]string{{"Name", "Cost"}, {"a", "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("got %q, want %q", rows, tt.want)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("Name\n\"a\n")); err == nil {
+		t.Error("unterminated quote parsed")
+	}
+}
+
+func TestEncodeParse(t *testing.T) {
+	rows := [][]string{{"Name", "Labels"}, {"a,b", `x="y"`}, {"", "line\nbreak"}}
+	data, err := Encode(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, rows) {
+		t.Errorf("got %q, want %q", parsed, rows)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	header := []string{"Name", "Cluster", "Cost"}
+	tests := []struct {
+		name   string
+		rows   [][]string
+		want   [][]string
+		change Change
+	}{
+		{
+			name: "current",
+			rows: [][]string{{"Name", "Cluster", "Cost"}, {"a", "prod", "1"}},
+			want: [][]string{{"Name", "Cluster", "Cost"}, {"a", "prod", "1"}},
+		},
+		{
+			name:   "added column",
+			rows:   [][]string{{"Name", "Cost"}, {"a", "1"}},
+			want:   [][]string{{"Name", "Cluster", "Cost"}, {"a", "", "1"}},
+			change: Change{Added: []string{"Cluster"}},
+		},
+		{
+			name:   "reordered",
+			rows:   [][]string{{"Cost", "Name", "Cluster"}, {"1", "a", "prod"}},
+			want:   [][]string{{"Name", "Cluster", "Cost"}, {"a", "prod", "1"}},
+			change: Change{Reorder: true},
+		},
+		{
+			name:   "legacy column kept at the end",
+			rows:   [][]string{{"Name", "Region", "Cluster", "Cost"}, {"a", "eu", "prod", "1"}},
+			want:   [][]string{{"Name", "Cluster", "Cost", "Region"}, {"a", "prod", "1", "eu"}},
+			change: Change{Legacy: []string{"Region"}},
+		},
+		{
+			name:   "short rows",
+			rows:   [][]string{{"Name", "Cost", "Cluster"}, {"a", "1"}},
+			want:   [][]string{{"Name", "Cluster", "Cost"}, {"a", "", "1"}},
+			change: Change{Reorder: true},
+		},
+		{
+			name: "current followed by legacy columns",
+			rows: [][]string{{"Name", "Cluster", "Cost", "Region"}, {"a", "prod", "1", "eu"}},
+			want: [][]string{{"Name", "Cluster", "Cost", "Region"}, {"a", "prod", "1", "eu"}},
+			// IsCurrent: the object is not rewritten, but Diff still reports the column.
+			change: Change{Legacy: []string{"Region"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Migrate(tt.rows, header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Migrate: got %q, want %q", got, tt.want)
+			}
+			if got := Diff(tt.rows[0], header); !reflect.DeepEqual(got, tt.change) {
+				t.Errorf("Diff: got %+v, want %+v", got, tt.change)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const key = "Pod/Pod.csv"
+	if err := store.Write(key, []byte("Name,Cost\na,1\n"), "text/csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := Append(store, key, []string{"Name", "Cluster", "Cost"}, [][]string{{"b", "prod", "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Cluster,Cost\na,,1\nb,prod,2\n"
+	if string(content) != want {
+		t.Errorf("got %q, want %q", content, want)
+	}
+	object, err := store.Read(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(object.Data) != want {
+		t.Errorf("stored %q, want %q", object.Data, want)
+	}
+}
+
+func TestMigrateObjects(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := schema.Header("Namespace")
+	old := append([]string{}, header[1:]...) // an object written before the first column existed
+	data, err := Encode([][]string{old, make([]string, len(old))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write("Namespace/Namespace.csv", data, "text/csv"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := Encode([][]string{schema.Header("Pod")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write("Pod/Pod.csv", current, "text/csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, apply := range []bool{false, true} {
+		count, err := MigrateObjects(store, apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("apply %t: %d objects need a migration, want 1", apply, count)
+		}
+	}
+	if count, err := MigrateObjects(store, false); err != nil || count != 0 {
+		t.Errorf("after migrating: %d objects, %v", count, err)
+	}
+	object, err := store.Read("Namespace/Namespace.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(object.Data), strings.Join(header, ",")+"\n") {
+		t.Errorf("header not migrated: %q", object.Data)
+	}
+}
@@ -0,0 +1,109 @@
+package history
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Load reads every stored object of an aggregation (e.g. "Namespace") and returns
+// its rows as allocations, sorted by window and name. Columns are mapped by name,
+// so objects written with older headers are read as well. A row collected more
+// than once, e.g. when a window was re-run, is returned once with the last values.
+func Load(store storage.Backend, aggregation string) ([]allocation.Allocation, error) {
+	keys, err := store.List(aggregation + "/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	byKey := map[string]allocation.Allocation{}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".csv") {
+			continue
+		}
+		object, err := store.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := Parse(object.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		index := columnIndex(rows[0])
+		for _, row := range rows[1:] {
+			a := record(aggregation, index, row)
+			byKey[a.Key()] = a
+		}
+	}
+
+	allocations := make([]allocation.Allocation, 0, len(byKey))
+	for _, a := range byKey {
+		allocations = append(allocations, a)
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		if allocations[i].WindowStart != allocations[j].WindowStart {
+			return allocations[i].WindowStart < allocations[j].WindowStart
+		}
+		return allocations[i].Name < allocations[j].Name
+	})
+	return allocations, nil
+}
+
+// record converts one CSV row to an allocation. The name is in the column named
+// after the aggregation; the Cluster aggregation has no ClusterName column.
+func record(aggregation string, index map[string]int, row []string) allocation.Allocation {
+	value := func(column string) string {
+		if i, ok := index[column]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	number := func(column string) float64 {
+		f, _ := strconv.ParseFloat(value(column), 64)
+		return f
+	}
+
+	a := allocation.Allocation{
+		Aggregation:      aggregation,
+		Name:             value(aggregation),
+		Cluster:          value("ClusterName"),
+		Region:           value("Region"),
+		Namespace:        value("Namespace"),
+		WindowStart:      value("Window Start"),
+		WindowEnd:        value("Window End"),
+		CpuCost:          number("Cpu Cost"),
+		GpuCost:          number("Gpu Cost"),
+		RamCost:          number("Ram Cost"),
+		PVCost:           number("PV Cost"),
+		NetworkCost:      number("Network Cost"),
+		LoadBalancerCost: number("LoadBalancer Cost"),
+		TotalCost:        number("Total Cost"),
+		CpuEfficiency:    number("Cpu Efficiency"),
+		RamEfficiency:    number("Ram Efficiency"),
+		TotalEfficiency:  number("Total Efficiency"),
+
+		CpuCoreRequestAverage:  number("Cpu Request Average"),
+		CpuCoreUsageAverage:    number("Cpu Usage Average"),
+		RamBytesRequestAverage: number("Ram Request Average"),
+		RamBytesUsageAverage:   number("Ram Usage Average"),
+	}
+	// The waste is derived rather than read, so rows written before the waste
+	// columns were added have it as well.
+	a.CpuWaste = allocation.Waste(a.CpuCost, a.CpuEfficiency)
+	a.RamWaste = allocation.Waste(a.RamCost, a.RamEfficiency)
+	if aggregation == "Namespace" && a.Namespace == "" {
+		a.Namespace = a.Name
+	}
+	if aggregation == "Cluster" {
+		a.Cluster = strings.TrimSuffix(strings.TrimPrefix(a.Name, "__idle__("), ")")
+	}
+	return a
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"strings"
+)
+
+// Change describes how the header of an existing object differs from the current one.
+type Change struct {
+	Added   []string // columns of the current header missing from the object
+	Legacy  []string // columns of the object that are no longer written; they are kept at the end
+	Reorder bool     // the shared columns are in a different order
+}
+
+// IsCurrent reports whether an object with the existing header is already laid
+// out according to header, optionally followed by legacy columns.
+func IsCurrent(existing, header []string) bool {
+	if len(existing) < len(header) {
+		return false
+	}
+	for i, name := range header {
+		if existing[i] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff compares the header of an existing object with the current header.
+func Diff(existing, header []string) Change {
+	var change Change
+	index := columnIndex(existing)
+	for _, name := range header {
+		if _, ok := index[name]; !ok {
+			change.Added = append(change.Added, name)
+		}
+	}
+
+	current := columnIndex(header)
+	var shared []string
+	for _, name := range existing {
+		if _, ok := current[name]; ok {
+			shared = append(shared, name)
+		} else {
+			change.Legacy = append(change.Legacy, name)
+		}
+	}
+	i := 0
+	for _, name := range header {
+		if i < len(shared) && name == shared[i] {
+			i++
+		} else if _, ok := index[name]; ok {
+			change.Reorder = true
+		}
+	}
+	return change
+}
+
+// Migrate rewrites rows (header first) to the current header. Values are mapped
+// by column name, columns that are new are left empty, and columns the current
+// header no longer has are kept after it so no data is lost.
+func Migrate(rows [][]string, header []string) [][]string {
+	existing := rows[0]
+	if IsCurrent(existing, header) {
+		return rows
+	}
+	change := Diff(existing, header)
+
+	target := append(append([]string{}, header...), change.Legacy...)
+	index := columnIndex(existing)
+
+	migrated := make([][]string, 0, len(rows))
+	migrated = append(migrated, target)
+	for _, row := range rows[1:] {
+		record := make([]string, len(target))
+		for i, name := range target {
+			if j, ok := index[name]; ok && j < len(row) {
+				record[i] = row[j]
+			}
+		}
+		migrated = append(migrated, record)
+	}
+	return migrated
+}
+
+func columnIndex(header []string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	return index
+}
+
+// MigrateObjects reports every stored object of every aggregation whose header
+// differs from the current schema and, if apply is set, rewrites it. It returns
+// the number of objects that need (or received) a migration.
+func MigrateObjects(store storage.Backend, apply bool) (int, error) {
+	count := 0
+	for _, aggregation := range schema.Aggregations {
+		header := schema.Header(aggregation)
+		keys, err := store.List(aggregation + "/")
+		if err != nil {
+			return count, err
+		}
+		for _, key := range keys {
+			if !strings.HasSuffix(key, ".csv") {
+				continue
+			}
+			object, err := store.Read(key)
+			if err != nil {
+				return count, err
+			}
+			rows, err := Parse(object.Data)
+			if err != nil {
+				return count, fmt.Errorf("%s: %w", key, err)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if IsCurrent(rows[0], header) {
+				continue
+			}
+			change := Diff(rows[0], header)
+			count++
+			configs.InfoLogger.Printf("%s: added columns %q, legacy columns %q, reordered %t\n", key, change.Added, change.Legacy, change.Reorder)
+			if !apply {
+				continue
+			}
+
+			content, err := Encode(Migrate(rows, header))
+			if err != nil {
+				return count, err
+			}
+			if err := store.WriteIf(key, content, "text/csv", object.Version); err != nil {
+				return count, fmt.Errorf("%s: %w", key, err)
+			}
+			configs.InfoLogger.Printf("%s: migrated %d rows\n", key, len(rows)-1)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,78 @@
+package jsonl
+
+import (
+	"bytes"
+	"encoding/json"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/storage"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Prefix is where the JSON Lines objects are stored, one directory per
+// aggregation so each can be a table location of its own.
+const Prefix = "JSONL/"
+
+// Sink writes the allocations of every aggregation as JSON Lines: one allocation
+// per line, with numeric fields as numbers and the Kubecost properties kept nested.
+type Sink struct {
+	store    storage.Backend
+	localDir string
+	cluster  string
+	day      string
+
+	mu sync.Mutex // Controller and Rollout are published by the same collector
+}
+
+// NewSink returns a sink writing JSONL/<Agg>/<Agg>-<cluster>-<day>.jsonl to store
+// and <Agg>-<day>.jsonl to localDir.
+func NewSink(store storage.Backend, localDir, cluster, day string) *Sink {
+	return &Sink{store: store, localDir: localDir, cluster: cluster, day: day}
+}
+
+func (s *Sink) Name() string {
+	return "jsonl"
+}
+
+// Key returns the object key of an aggregation's rows of a cluster and day.
+func Key(aggregation, cluster, day string) string {
+	return Prefix + aggregation + "/" + aggregation + "-" + cluster + "-" + day + ".jsonl"
+}
+
+// Encode returns the allocations as JSON Lines.
+func Encode(allocations []allocation.Allocation) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, a := range allocations {
+		if err := encoder.Encode(a); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Write replaces the object of the aggregation, cluster and day, so a re-run of a Window
+// does not duplicate lines.
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	data, err := Encode(allocations)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.localDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.localDir, aggregation+"-"+s.day+".jsonl"), data, 0644); err != nil {
+		return err
+	}
+	return s.store.Write(Key(aggregation, s.cluster, s.day), data, "application/x-ndjson")
+}
+
+func (s *Sink) Close() error {
+	return nil
+}
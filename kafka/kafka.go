@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Config holds the producer settings of the Kafka sink.
+type Config struct {
+	Brokers      []string
+	TopicPrefix  string            // topic of an aggregation is TopicPrefix + snake_case aggregation
+	Topics       map[string]string // per-aggregation topic overrides
+	RequiredAcks int               // -1 all in-sync replicas, 1 leader only, 0 none
+	BatchSize    int
+	BatchTimeout time.Duration
+}
+
+// Sink publishes every allocation as a JSON message. Messages are keyed by
+// Allocation.Key, so all versions of a row land on the same partition.
+type Sink struct {
+	config Config
+	writer *kafkago.Writer
+}
+
+// Open checks that one of the brokers answers and returns the sink.
+func Open(config Config) (*Sink, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers configured")
+	}
+	if err := dial(config.Brokers); err != nil {
+		return nil, err
+	}
+
+	writer := &kafkago.Writer{
+		Addr:                   kafkago.TCP(config.Brokers...),
+		Balancer:               &kafkago.Hash{},
+		RequiredAcks:           kafkago.RequiredAcks(config.RequiredAcks),
+		BatchSize:              config.BatchSize,
+		BatchTimeout:           config.BatchTimeout,
+		AllowAutoTopicCreation: true,
+	}
+	return &Sink{config: config, writer: writer}, nil
+}
+
+// dial tries each broker in turn until one answers.
+func dial(brokers []string) error {
+	var errs []error
+	for _, broker := range brokers {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conn, err := kafkago.DialContext(ctx, "tcp", broker)
+		cancel()
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+	}
+	return fmt.Errorf("no Kafka broker answered: %w", errors.Join(errs...))
+}
+
+func (s *Sink) Name() string {
+	return "kafka"
+}
+
+// Topic returns the topic the allocations of an aggregation are published to.
+func (s *Sink) Topic(aggregation string) string {
+	if topic, ok := s.config.Topics[aggregation]; ok {
+		return topic
+	}
+	return s.config.TopicPrefix + schema.ColumnName(aggregation)
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+
+	topic := s.Topic(aggregation)
+	messages := make([]kafkago.Message, 0, len(allocations))
+	for _, a := range allocations {
+		value, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafkago.Message{
+			Topic: topic,
+			Key:   []byte(a.Key()),
+			Value: value,
+			Headers: []kafkago.Header{
+				{Key: "run_id", Value: []byte(configs.RunID)},
+				{Key: "aggregation", Value: []byte(aggregation)},
+			},
+		})
+	}
+	return s.writer.WriteMessages(context.Background(), messages...)
+}
+
+func (s *Sink) Close() error {
+	return s.writer.Close()
+}
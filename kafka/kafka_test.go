@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDialTriesEachBroker(t *testing.T) {
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+	up, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	go func() {
+		for {
+			conn, err := up.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	if err := dial([]string{down.Addr().String(), up.Addr().String()}); err != nil {
+		t.Errorf("second broker not tried: %v", err)
+	}
+	if err := dial([]string{down.Addr().String()}); err == nil {
+		t.Error("no error without a broker")
+	}
+}
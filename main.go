@@ -1,41 +1,255 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"kubecost-efficiency-fetcher/athena"
+	"kubecost-efficiency-fetcher/budget"
 	"kubecost-efficiency-fetcher/cluster"
+	"kubecost-efficiency-fetcher/compaction"
 	"kubecost-efficiency-fetcher/controller"
 	"kubecost-efficiency-fetcher/controllerKind"
 	"kubecost-efficiency-fetcher/deployment"
+	"kubecost-efficiency-fetcher/email"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/jsonl"
+	"kubecost-efficiency-fetcher/manifest"
 	"kubecost-efficiency-fetcher/namespace"
 	"kubecost-efficiency-fetcher/node"
 	"kubecost-efficiency-fetcher/pod"
+	"kubecost-efficiency-fetcher/schema"
 	"kubecost-efficiency-fetcher/service"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
+	"kubecost-efficiency-fetcher/xlsx"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+	"github.com/aws/aws-sdk-go/service/glue"
 )
 
+const usage = `Usage: kubecost-efficiency-fetcher [command]
 
+Commands:
+  (none)    fetch the allocation data of the configured Window and append it to the history
+  compact   drop rows older than the retention and roll completed months into monthly objects
+  migrate   report stored objects whose header differs from the current schema
+            (-apply rewrites them)
+  verify    check the objects listed in the latest run manifest (-run <id> for another run)
+  digest    post the daily digest of the configured Window to Slack and Teams (-dry-run prints it)
+  email     send the HTML email report of the last EmailReportDays days
+            (-days <n>, -dry-run writes the messages to Output/Email)
+  report    write the HTML dashboard of the last DashboardDays days to Output/dashboard.html
+            (-days <n>, -publish also stores it at DashboardKey)
+  markdown  print a Markdown cost report of the configured Window
+            (-window <start>,<end>, -compare <start>,<end>|previous, -out <file>)
+  rightsize write CPU and RAM request recommendations from the last RightsizingDays days
+            to Output/Rightsizing.csv (-days <n>)
+  wasters   rank the workloads of the last WastersDays days by CPU and RAM waste and write
+            them to Output/Wasters.csv (-days <n>, -min-cost <amount>, -namespace <patterns>)
+  anomalies compare the configured Window with the stored history and write the cost
+            anomalies to Output/Anomalies.csv (-window <start>,<end>)
+  budgets   write the month-to-date status of the configured budgets to Output/BudgetStatus.csv
+            and raise their alerts (-window <start>,<end>)
+  serve     collect every day and serve the latest values as Prometheus gauges on /metrics
+            (-listen <addr>)
+`
 
 func main() {
 
+	store, err := storage.New()
+	if err != nil {
+		configs.ErrorLogger.Println("Error creating storage backend:", err)
+		os.Exit(1)
+	}
+
+	if configs.StorageBackend == "local" {
+		if err := importLocalCopies(store); err != nil {
+			configs.ErrorLogger.Println("Error importing the local history:", err)
+			os.Exit(1)
+		}
+	}
+
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "":
+		collect(store)
+	case "compact":
+		if err := compaction.Run(store, time.Now()); err != nil {
+			configs.ErrorLogger.Println("Error compacting history:", err)
+			os.Exit(1)
+		}
+	case "migrate":
+		migrate(store, os.Args[2:])
+	case "verify":
+		verify(store, os.Args[2:])
+	case "digest":
+		sendDigest(store, os.Args[2:])
+	case "email":
+		sendEmail(store, os.Args[2:])
+	case "report":
+		writeDashboard(store, os.Args[2:])
+	case "markdown":
+		writeMarkdown(store, os.Args[2:])
+	case "rightsize":
+		rightsize(store, os.Args[2:])
+	case "wasters":
+		rankWasters(store, os.Args[2:])
+	case "anomalies":
+		detect(store, os.Args[2:])
+	case "budgets":
+		evaluateBudgetsOnly(store, os.Args[2:])
+	case "serve":
+		serve(store, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// importedKey marks a local store into which the local copies were imported.
+const importedKey = "LocalCopiesImported"
+
+// importLocalCopies copies the local copies OutputDir/<Aggregation>.csv, the only
+// history kept on disk before the local backend existed, into the store as
+// <Aggregation>/<Aggregation>.csv. It runs once per store: afterwards importedKey is
+// written, so copies left behind are not imported again once compaction has expired
+// or rolled up the objects.
+func importLocalCopies(store storage.Backend) error {
+	_, err := store.Read(importedKey)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	for _, aggregation := range schema.Aggregations {
+		path := filepath.Join(configs.OutputDir, aggregation+".csv")
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		keys, err := store.List(aggregation + "/")
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			continue
+		}
+
+		key := aggregation + "/" + aggregation + ".csv"
+		if err := store.WriteIf(key, data, "text/csv", ""); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		configs.InfoLogger.Printf("Imported the history in %s as %s\n", path, key)
+	}
+	return store.Write(importedKey, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), "text/plain")
+}
+
+// collect runs every collector for the configured Window, waits for them to finish
+// and writes the run manifest.
+func collect(store storage.Backend) {
+
+	recorder := manifest.NewRecorder(store, configs.RunID, configs.ClusterName, configs.Window)
+	store = recorder
+
+	sink.Open()
+
+	day := strings.SplitN(configs.Window, "T", 2)[0]
+	if configs.ExportJSONL {
+		sink.Add(jsonl.NewSink(store, configs.OutputDir, configs.ClusterName, day))
+	}
+	if configs.ExportXLSX {
+		key := "Excel/Kubecost-" + configs.ClusterName + "-" + day + ".xlsx"
+		local := filepath.Join(configs.OutputDir, "Kubecost-"+day+".xlsx")
+		sink.Add(xlsx.NewSink(store, key, local, configs.ClusterName, configs.Window, configs.RunID))
+	}
+	budgets := budget.NewSink(configs.Budgets)
+	if len(configs.Budgets) > 0 {
+		sink.Add(budgets)
+	}
+	windowStart, _, _ := strings.Cut(configs.Window, ",")
+	emailLabels := email.NewLabelSink(windowStart)
+	if len(configs.EmailLabelRecipients) > 0 {
+		sink.Add(emailLabels)
+	}
+
 	wg := &sync.WaitGroup{}
 	wg.Add(8)
 	
-	go cluster.FetchAndWriteClusterData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg) 
+	go cluster.FetchAndWriteClusterData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg) 
 	
-	go node.FetchAndWriteNodeData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go node.FetchAndWriteNodeData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
-	go pod.FetchAndWritePodData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go pod.FetchAndWritePodData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
-	go namespace.FetchAndWriteNamespaceData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, wg) 
+	go namespace.FetchAndWriteNamespaceData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,store, wg) 
 	
-	go service.FetchAndWriteServiceData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go service.FetchAndWriteServiceData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
-	go deployment.FetchAndWriteDeploymentData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go deployment.FetchAndWriteDeploymentData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
-	go controller.FetchAndWriteControllerData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go controller.FetchAndWriteControllerData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
-	go controllerKind.FetchAndWriteControllerKindData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketName, configs.BucketRegion, wg)
+	go controllerKind.FetchAndWriteControllerKindData(configs.KubecostEndpoint,configs.ClusterName,configs.Window,configs.BucketRegion, store, wg)
 
 	wg.Wait()
 
+	sink.Close()
+
+	if configs.DetectAnomalies {
+		anomalies, err := detectAnomalies(store, configs.Window)
+		if err != nil {
+			configs.ErrorLogger.Println("Error detecting anomalies:", err)
+		}
+		recorder.SetAnomalies(anomalies)
+	}
+
+	if len(configs.Budgets) > 0 {
+		if err := evaluateBudgets(store, configs.Window, budgets.LabelSpend()); err != nil {
+			configs.ErrorLogger.Println("Error evaluating budgets:", err)
+		}
+	}
+
+	if len(configs.EmailLabelRecipients) > 0 {
+		if err := emailLabels.Save(store, configs.EmailLabelsKey); err != nil {
+			configs.ErrorLogger.Println("Error recording email labels:", err)
+		}
+	}
+
+	m, err := recorder.Save()
+	if err != nil {
+		configs.ErrorLogger.Println("Error writing run manifest:", err)
+	} else if !m.Complete {
+		configs.ErrorLogger.Printf("Run %s is incomplete, no data was written for %v\n", m.RunID, m.Missing)
+	} else {
+		configs.InfoLogger.Printf("Run %s complete, manifest written to %s\n", m.RunID, manifest.Key(m.Cluster, m.RunID))
+	}
+
+	if len(configs.DigestChannels) > 0 || len(configs.DigestNamespaceRoutes) > 0 {
+		if err := sendDigests(store, false); err != nil {
+			configs.ErrorLogger.Println("Error sending digest:", err)
+		}
+	}
+
+	if err := athena.WriteDDL(); err != nil {
+		configs.ErrorLogger.Println("Error writing Athena table definitions:", err)
+	}
+
+	if configs.RegisterGlueTables && configs.Sess != nil {
+		if err := athena.RegisterTables(glue.New(configs.Sess)); err != nil {
+			configs.ErrorLogger.Println("Error registering Glue tables:", err)
+		}
+	}
 }
@@ -0,0 +1,151 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"kubecost-efficiency-fetcher/anomaly"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix is where the manifests are stored, one object per cluster and run.
+const Prefix = "Manifests/"
+
+// Object describes one object written during a run.
+type Object struct {
+	Key           string `json:"key"`
+	Aggregation   string `json:"aggregation"`
+	Size          int    `json:"size"`
+	SHA256        string `json:"sha256"`
+	Rows          int    `json:"rows"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// Manifest lists everything a run wrote. Missing names the aggregations for
+// which nothing was written, e.g. because their collector failed. Anomalies are
+// the cost anomalies detected in the Window, when detection is enabled.
+type Manifest struct {
+	RunID         string    `json:"runId"`
+	Cluster       string    `json:"cluster"`
+	Window        string    `json:"window"`
+	SchemaVersion int       `json:"schemaVersion"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Complete      bool      `json:"complete"`
+	Missing       []string  `json:"missing,omitempty"`
+	Objects       []Object  `json:"objects"`
+
+	Anomalies []anomaly.Anomaly `json:"anomalies,omitempty"`
+}
+
+// Key returns the key of the manifest of a run of cluster. Run IDs are timestamps,
+// so clusters sharing a store keep their manifests apart.
+func Key(cluster, runID string) string {
+	return Prefix + cluster + "/" + runID + ".json"
+}
+
+// Recorder is a storage backend that records every object written through it.
+type Recorder struct {
+	storage.Backend
+
+	mu       sync.Mutex
+	manifest Manifest
+	objects  map[string]Object
+}
+
+func NewRecorder(store storage.Backend, runID, cluster, window string) *Recorder {
+	return &Recorder{
+		Backend: store,
+		manifest: Manifest{
+			RunID:         runID,
+			Cluster:       cluster,
+			Window:        window,
+			SchemaVersion: schema.Version,
+			StartedAt:     time.Now().UTC(),
+		},
+		objects: map[string]Object{},
+	}
+}
+
+func (r *Recorder) Write(key string, data []byte, contentType string) error {
+	if err := r.Backend.Write(key, data, contentType); err != nil {
+		return err
+	}
+	r.record(key, data)
+	return nil
+}
+
+func (r *Recorder) WriteIf(key string, data []byte, contentType, version string) error {
+	if err := r.Backend.WriteIf(key, data, contentType, version); err != nil {
+		return err
+	}
+	r.record(key, data)
+	return nil
+}
+
+func (r *Recorder) record(key string, data []byte) {
+	object := Describe(key, data)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.objects[key] = object
+}
+
+// SetAnomalies records the anomalies detected in the Window of the run.
+func (r *Recorder) SetAnomalies(anomalies []anomaly.Anomaly) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifest.Anomalies = anomalies
+}
+
+// Describe computes the manifest entry of an object's content.
+func Describe(key string, data []byte) Object {
+	sum := sha256.Sum256(data)
+	rows := 0
+	if records, err := history.Parse(data); err == nil && len(records) > 0 {
+		rows = len(records) - 1
+	}
+	return Object{
+		Key:           key,
+		Aggregation:   strings.SplitN(key, "/", 2)[0],
+		Size:          len(data),
+		SHA256:        hex.EncodeToString(sum[:]),
+		Rows:          rows,
+		SchemaVersion: schema.Version,
+	}
+}
+
+// Save completes the manifest and writes it to the underlying backend.
+func (r *Recorder) Save() (*Manifest, error) {
+	r.mu.Lock()
+	m := r.manifest
+	written := map[string]bool{}
+	for _, object := range r.objects {
+		m.Objects = append(m.Objects, object)
+		written[object.Aggregation] = true
+	}
+	r.mu.Unlock()
+
+	sort.Slice(m.Objects, func(i, j int) bool { return m.Objects[i].Key < m.Objects[j].Key })
+	for _, aggregation := range schema.Aggregations {
+		if !written[aggregation] {
+			m.Missing = append(m.Missing, aggregation)
+		}
+	}
+	m.Complete = len(m.Missing) == 0
+	m.FinishedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Backend.Write(Key(m.Cluster, m.RunID), data, "application/json"); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
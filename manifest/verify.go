@@ -0,0 +1,76 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"strings"
+)
+
+// Load reads the manifest of runID of cluster, or of its latest run if runID is empty.
+func Load(store storage.Backend, cluster, runID string) (*Manifest, error) {
+	key := Key(cluster, runID)
+	if runID == "" {
+		keys, err := store.List(Prefix + cluster + "/")
+		if err != nil {
+			return nil, err
+		}
+		var manifests []string
+		for _, k := range keys {
+			if strings.HasSuffix(k, ".json") {
+				manifests = append(manifests, k)
+			}
+		}
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("no manifest found for cluster %s", cluster)
+		}
+		sort.Strings(manifests)
+		key = manifests[len(manifests)-1]
+	}
+
+	object, err := store.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(object.Data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &m, nil
+}
+
+// Problem is an object that no longer matches its manifest entry.
+type Problem struct {
+	Key    string
+	Reason string
+}
+
+// Verify reads every object listed in the manifest and compares its size,
+// checksum and row count. Objects that were appended to by a later run are
+// reported as well, so verify the manifest of the latest run.
+func Verify(store storage.Backend, m *Manifest) ([]Problem, error) {
+	var problems []Problem
+	for _, expected := range m.Objects {
+		object, err := store.Read(expected.Key)
+		if errors.Is(err, storage.ErrNotFound) {
+			problems = append(problems, Problem{Key: expected.Key, Reason: "object is missing"})
+			continue
+		}
+		if err != nil {
+			return problems, err
+		}
+
+		actual := Describe(expected.Key, object.Data)
+		switch {
+		case actual.Size != expected.Size:
+			problems = append(problems, Problem{Key: expected.Key, Reason: fmt.Sprintf("size is %d bytes, expected %d", actual.Size, expected.Size)})
+		case actual.SHA256 != expected.SHA256:
+			problems = append(problems, Problem{Key: expected.Key, Reason: "SHA-256 does not match"})
+		case actual.Rows != expected.Rows:
+			problems = append(problems, Problem{Key: expected.Key, Reason: fmt.Sprintf("has %d rows, expected %d", actual.Rows, expected.Rows)})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,102 @@
+package markdown
+
+import (
+	"errors"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/report"
+	"kubecost-efficiency-fetcher/storage"
+	"strings"
+	"time"
+)
+
+// Period is a time range in the Window format, the end being exclusive.
+type Period struct {
+	Start, End time.Time
+}
+
+// ParsePeriod parses a Window such as "2024-07-27T00:00:00Z,2024-07-28T00:00:00Z".
+func ParsePeriod(window string) (Period, error) {
+	start, end, ok := strings.Cut(window, ",")
+	if !ok {
+		return Period{}, errors.New("window must be <start>,<end>")
+	}
+	var p Period
+	var err error
+	if p.Start, err = time.Parse(time.RFC3339, start); err != nil {
+		return Period{}, err
+	}
+	if p.End, err = time.Parse(time.RFC3339, end); err != nil {
+		return Period{}, err
+	}
+	if !p.End.After(p.Start) {
+		return Period{}, errors.New("window ends before it starts")
+	}
+	return p, nil
+}
+
+// Previous returns the period of the same length that ends where p starts.
+func (p Period) Previous() Period {
+	return Period{Start: p.Start.Add(-p.End.Sub(p.Start)), End: p.Start}
+}
+
+// Window is the collected data of one period.
+type Window struct {
+	Period
+
+	// Totals sums the Cluster rows, including idle. Efficiencies are weighted by
+	// the CPU, RAM and total cost respectively.
+	Totals allocation.Allocation
+
+	Lines map[string][]report.Line // by aggregation, most expensive first
+}
+
+// Load reads the history of the Cluster rows and of the given aggregations once and
+// returns a Window of cluster for each period.
+func Load(store storage.Backend, cluster string, aggregations []string, periods ...Period) ([]*Window, error) {
+	loaded := map[string][]allocation.Allocation{}
+	for _, aggregation := range append([]string{"Cluster"}, aggregations...) {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		loaded[aggregation] = report.OfCluster(allocations, cluster)
+	}
+
+	windows := make([]*Window, len(periods))
+	for i, p := range periods {
+		w := &Window{Period: p, Lines: map[string][]report.Line{}}
+		w.Totals = totals(report.InPeriod(loaded["Cluster"], p.Start, p.End))
+		for _, aggregation := range aggregations {
+			w.Lines[aggregation] = report.Lines(report.InPeriod(loaded[aggregation], p.Start, p.End))
+		}
+		windows[i] = w
+	}
+	return windows, nil
+}
+
+func totals(allocations []allocation.Allocation) allocation.Allocation {
+	var t allocation.Allocation
+	for _, a := range allocations {
+		t.CpuCost += a.CpuCost
+		t.GpuCost += a.GpuCost
+		t.RamCost += a.RamCost
+		t.PVCost += a.PVCost
+		t.NetworkCost += a.NetworkCost
+		t.LoadBalancerCost += a.LoadBalancerCost
+		t.TotalCost += a.TotalCost
+		t.CpuEfficiency += a.CpuCost * a.CpuEfficiency
+		t.RamEfficiency += a.RamCost * a.RamEfficiency
+		t.TotalEfficiency += a.TotalCost * a.TotalEfficiency
+	}
+	if t.CpuCost > 0 {
+		t.CpuEfficiency /= t.CpuCost
+	}
+	if t.RamCost > 0 {
+		t.RamEfficiency /= t.RamCost
+	}
+	if t.TotalCost > 0 {
+		t.TotalEfficiency /= t.TotalCost
+	}
+	return t
+}
@@ -0,0 +1,317 @@
+package markdown
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/report"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Sections that can be listed in Options.Sections.
+const (
+	Totals     = "totals"     // Cluster totals by cost type
+	Cost       = "cost"       // top lines of each aggregation by cost
+	Waste      = "waste"      // top lines of each aggregation by waste
+	Efficiency = "efficiency" // largest efficiency changes; needs a previous window
+)
+
+// Options select what Render writes.
+type Options struct {
+	Title        string
+	Cluster      string
+	Sections     []string // in order, e.g. {Totals, Cost, Waste, Efficiency}
+	Aggregations []string // e.g. {"Namespace", "Deployment", "Controller"}
+	TopN         int      // rows per table
+	Currency     string
+}
+
+// Render writes the report of current as GitHub flavored Markdown, which Confluence
+// also accepts. When previous is not nil every table is compared with it.
+func Render(current, previous *Window, o Options) (string, error) {
+	r := &renderer{current: current, previous: previous, o: o}
+	fmt.Fprintf(&r.b, "# %s: %s\n\n", escape(o.Title), escape(o.Cluster))
+	if previous == nil {
+		fmt.Fprintf(&r.b, "%s\n", period(current.Period))
+	} else {
+		fmt.Fprintf(&r.b, "%s compared with %s\n", period(current.Period), period(previous.Period))
+	}
+
+	for _, section := range o.Sections {
+		switch section {
+		case Totals:
+			r.totals()
+		case Cost:
+			for _, aggregation := range o.Aggregations {
+				r.top(aggregation, "by cost", current.Lines[aggregation], func(l report.Line) float64 { return l.Cost })
+			}
+		case Waste:
+			for _, aggregation := range o.Aggregations {
+				r.top(aggregation, "by waste", byWaste(current.Lines[aggregation]), func(l report.Line) float64 { return l.Waste })
+			}
+		case Efficiency:
+			if previous == nil {
+				continue
+			}
+			for _, aggregation := range o.Aggregations {
+				r.efficiencyChanges(aggregation)
+			}
+		default:
+			return "", fmt.Errorf("unknown section %q", section)
+		}
+	}
+	r.b.WriteString("\nEfficiencies are cost-weighted averages over the period. Waste is the CPU and RAM cost multiplied by (1 - CPU and RAM efficiency).\n")
+	return r.b.String(), nil
+}
+
+type renderer struct {
+	current, previous *Window
+	o                 Options
+	b                 strings.Builder
+}
+
+func (r *renderer) money(value float64) string {
+	if value < 0 {
+		return fmt.Sprintf("-%s%.2f", r.o.Currency, -value)
+	}
+	return fmt.Sprintf("%s%.2f", r.o.Currency, value)
+}
+
+// change formats the difference to a previous value, e.g. "+$1.20 (+8.0%)".
+func (r *renderer) change(current, previous float64) string {
+	delta := current - previous
+	sign := "+"
+	if delta < 0 {
+		sign = ""
+	}
+	if previous == 0 {
+		return sign + r.money(delta)
+	}
+	return fmt.Sprintf("%s%s (%s%.1f%%)", sign, r.money(delta), sign, delta/previous*100)
+}
+
+func percent(value float64) string {
+	return fmt.Sprintf("%.1f%%", value)
+}
+
+// points formats an efficiency difference in percentage points, e.g. "+2.5 pp".
+func points(delta float64) string {
+	if delta < 0 {
+		return fmt.Sprintf("%.1f pp", delta)
+	}
+	return fmt.Sprintf("+%.1f pp", delta)
+}
+
+func (r *renderer) totals() {
+	c := r.current.Totals
+	rows := []struct {
+		label             string
+		current, previous float64
+		efficiency        bool
+	}{
+		{"Total cost", c.TotalCost, 0, false},
+		{"CPU cost", c.CpuCost, 0, false},
+		{"GPU cost", c.GpuCost, 0, false},
+		{"RAM cost", c.RamCost, 0, false},
+		{"PV cost", c.PVCost, 0, false},
+		{"Network cost", c.NetworkCost, 0, false},
+		{"Load balancer cost", c.LoadBalancerCost, 0, false},
+		{"CPU efficiency", c.CpuEfficiency, 0, true},
+		{"RAM efficiency", c.RamEfficiency, 0, true},
+		{"Total efficiency", c.TotalEfficiency, 0, true},
+	}
+	if r.previous != nil {
+		p := r.previous.Totals
+		for i, value := range []float64{p.TotalCost, p.CpuCost, p.GpuCost, p.RamCost, p.PVCost, p.NetworkCost, p.LoadBalancerCost, p.CpuEfficiency, p.RamEfficiency, p.TotalEfficiency} {
+			rows[i].previous = value
+		}
+	}
+
+	t := table{header: []string{"", "Current"}, right: []bool{false, true}}
+	if r.previous != nil {
+		t.header = append(t.header, "Previous", "Change")
+		t.right = append(t.right, true, true)
+	}
+	for _, row := range rows {
+		format := r.money
+		if row.efficiency {
+			format = percent
+		}
+		cells := []string{row.label, format(row.current)}
+		if r.previous != nil {
+			if row.efficiency {
+				cells = append(cells, format(row.previous), points(row.current-row.previous))
+			} else {
+				cells = append(cells, format(row.previous), r.change(row.current, row.previous))
+			}
+		}
+		t.rows = append(t.rows, cells)
+	}
+	r.b.WriteString("\n## Cluster totals\n\n")
+	t.write(&r.b)
+}
+
+// top writes the first TopN lines, which are already sorted by value.
+func (r *renderer) top(aggregation, order string, lines []report.Line, value func(report.Line) float64) {
+	previous := r.previousLines(aggregation)
+	column := map[string]string{"by cost": "Cost", "by waste": "Waste"}[order]
+	t := r.lineTable(aggregation, column)
+	for _, l := range lines {
+		if len(t.rows) == r.o.TopN || value(l) <= 0 {
+			break
+		}
+		cells := r.nameCells(aggregation, l)
+		cells = append(cells, r.money(value(l)))
+		if previous != nil {
+			p := previous[key(l)]
+			cells = append(cells, r.money(value(p)), r.change(value(l), value(p)))
+		}
+		if column == "Cost" {
+			cells = append(cells, percent(l.Efficiency), r.money(l.Waste))
+		} else {
+			cells = append(cells, r.money(l.Cost), percent(l.Efficiency))
+		}
+		t.rows = append(t.rows, cells)
+	}
+	fmt.Fprintf(&r.b, "\n## Top %ss %s\n\n", aggregation, order)
+	r.writeTable(t)
+}
+
+// efficiencyChanges lists the lines of both windows whose efficiency changed most.
+// Changes that round to 0.0 percentage points are left out.
+func (r *renderer) efficiencyChanges(aggregation string) {
+	previous := r.previousLines(aggregation)
+	type changed struct {
+		line     report.Line
+		previous float64
+	}
+	var changes []changed
+	for _, l := range r.current.Lines[aggregation] {
+		p, ok := previous[key(l)]
+		if ok && l.Cost > 0 && p.Cost > 0 && math.Abs(l.Efficiency-p.Efficiency) >= 0.05 {
+			changes = append(changes, changed{l, p.Efficiency})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return math.Abs(changes[i].line.Efficiency-changes[i].previous) > math.Abs(changes[j].line.Efficiency-changes[j].previous)
+	})
+
+	t := table{header: []string{aggregation}, right: []bool{false}}
+	if aggregation != "Namespace" {
+		t.header = append(t.header, "Namespace")
+		t.right = append(t.right, false)
+	}
+	t.header = append(t.header, "Previous", "Current", "Change", "Cost")
+	t.right = append(t.right, true, true, true, true)
+	for _, c := range changes {
+		if len(t.rows) == r.o.TopN {
+			break
+		}
+		cells := r.nameCells(aggregation, c.line)
+		cells = append(cells, percent(c.previous), percent(c.line.Efficiency), points(c.line.Efficiency-c.previous), r.money(c.line.Cost))
+		t.rows = append(t.rows, cells)
+	}
+	fmt.Fprintf(&r.b, "\n## %s efficiency changes\n\n", aggregation)
+	r.writeTable(t)
+}
+
+func (r *renderer) writeTable(t table) {
+	if len(t.rows) == 0 {
+		r.b.WriteString("None.\n")
+		return
+	}
+	t.write(&r.b)
+}
+
+// lineTable returns the header of the cost and waste tables, column being the
+// name of the sorted value.
+func (r *renderer) lineTable(aggregation, column string) table {
+	t := table{header: []string{aggregation}, right: []bool{false}}
+	if aggregation != "Namespace" {
+		t.header = append(t.header, "Namespace")
+		t.right = append(t.right, false)
+	}
+	t.header = append(t.header, column)
+	t.right = append(t.right, true)
+	if r.previous != nil {
+		t.header = append(t.header, "Previous", "Change")
+		t.right = append(t.right, true, true)
+	}
+	if column == "Cost" {
+		t.header = append(t.header, "Efficiency", "Waste")
+	} else {
+		t.header = append(t.header, "Cost", "Efficiency")
+	}
+	t.right = append(t.right, true, true)
+	return t
+}
+
+func (r *renderer) nameCells(aggregation string, l report.Line) []string {
+	if aggregation == "Namespace" {
+		return []string{l.Name}
+	}
+	return []string{l.Name, l.Namespace}
+}
+
+func (r *renderer) previousLines(aggregation string) map[[2]string]report.Line {
+	if r.previous == nil {
+		return nil
+	}
+	lines := map[[2]string]report.Line{}
+	for _, l := range r.previous.Lines[aggregation] {
+		lines[key(l)] = l
+	}
+	return lines
+}
+
+func key(l report.Line) [2]string {
+	return [2]string{l.Namespace, l.Name}
+}
+
+func byWaste(lines []report.Line) []report.Line {
+	sorted := append([]report.Line(nil), lines...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Waste > sorted[j].Waste })
+	return sorted
+}
+
+func period(p Period) string {
+	last := p.End.AddDate(0, 0, -1)
+	if !last.After(p.Start) {
+		return p.Start.Format("2006-01-02")
+	}
+	return p.Start.Format("2006-01-02") + " to " + last.Format("2006-01-02")
+}
+
+type table struct {
+	header []string
+	right  []bool // right-aligned columns
+	rows   [][]string
+}
+
+func (t table) write(b *strings.Builder) {
+	b.WriteString("|")
+	for _, h := range t.header {
+		b.WriteString(" " + escape(h) + " |")
+	}
+	b.WriteString("\n|")
+	for _, right := range t.right {
+		if right {
+			b.WriteString(" ---: |")
+		} else {
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteString("\n")
+	for _, row := range t.rows {
+		b.WriteString("|")
+		for _, cell := range row {
+			b.WriteString(" " + escape(cell) + " |")
+		}
+		b.WriteString("\n")
+	}
+}
+
+// escape keeps names from breaking the table or being read as formatting.
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "*", `\*`, "_", `\_`, "`", "\\`").Replace(s)
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/schema"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var labels = []string{"aggregation", "name", "namespace", "cluster"}
+
+var (
+	totalCost = prometheus.NewDesc("kubecost_allocation_total_cost",
+		"Total cost of the allocation over the collected window.", labels, nil)
+	cpuEfficiency = prometheus.NewDesc("kubecost_allocation_cpu_efficiency",
+		"CPU efficiency of the allocation over the collected window, as a ratio (0-1).", labels, nil)
+	ramEfficiency = prometheus.NewDesc("kubecost_allocation_ram_efficiency",
+		"RAM efficiency of the allocation over the collected window, as a ratio (0-1).", labels, nil)
+	totalEfficiency = prometheus.NewDesc("kubecost_allocation_total_efficiency",
+		"Total efficiency of the allocation over the collected window, as a ratio (0-1).", labels, nil)
+	dropped = prometheus.NewDesc("kubecost_allocation_series_dropped",
+		"Allocations of the aggregation not exported because of the cardinality limit.", []string{"aggregation"}, nil)
+	lastCollection = prometheus.NewDesc("kubecost_allocation_last_collection_timestamp_seconds",
+		"Time the aggregation was last collected.", []string{"aggregation"}, nil)
+)
+
+type snapshot struct {
+	allocations []allocation.Allocation
+	dropped     int
+	collectedAt time.Time
+}
+
+// Exporter keeps the latest allocations of every aggregation and serves them as
+// Prometheus gauges. It is a sink, so the collectors feed it like any other output.
+type Exporter struct {
+	limits       map[string]int
+	defaultLimit int
+
+	mu        sync.Mutex
+	snapshots map[string]snapshot
+}
+
+// NewExporter returns an exporter that keeps at most limits[aggregation] series of
+// an aggregation, or defaultLimit for aggregations without an entry. A limit of 0
+// excludes the aggregation.
+func NewExporter(limits map[string]int, defaultLimit int) *Exporter {
+	return &Exporter{limits: limits, defaultLimit: defaultLimit, snapshots: map[string]snapshot{}}
+}
+
+func (e *Exporter) Name() string {
+	return "prometheus"
+}
+
+func (e *Exporter) limit(aggregation string) int {
+	if limit, ok := e.limits[aggregation]; ok {
+		return limit
+	}
+	return e.defaultLimit
+}
+
+// Write replaces the snapshot of an aggregation. When it has more allocations than
+// the limit, the most expensive ones are kept.
+func (e *Exporter) Write(aggregation string, allocations []allocation.Allocation) error {
+	limit := e.limit(aggregation)
+	if limit <= 0 {
+		return nil
+	}
+
+	// A label set may only be exported once, so allocations that share one are
+	// summed into a single series rather than dropped.
+	kept := allocation.MergeBy(allocations, seriesKey)
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].TotalCost > kept[j].TotalCost })
+
+	s := snapshot{allocations: kept, collectedAt: time.Now()}
+	if len(kept) > limit {
+		s.dropped = len(kept) - limit
+		s.allocations = kept[:limit]
+	}
+
+	e.mu.Lock()
+	e.snapshots[aggregation] = s
+	e.mu.Unlock()
+	return nil
+}
+
+// seriesKey identifies the series of an allocation by its labels.
+func seriesKey(a allocation.Allocation) string {
+	return a.Name + "\x00" + a.Namespace + "\x00" + a.Cluster
+}
+
+// Close keeps the snapshots, so the gauges are still served between collections.
+func (e *Exporter) Close() error {
+	return nil
+}
+
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{totalCost, cpuEfficiency, ramEfficiency, totalEfficiency, dropped, lastCollection} {
+		ch <- desc
+	}
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for aggregation, s := range e.snapshots {
+		label := schema.ColumnName(aggregation)
+		for _, a := range s.allocations {
+			values := []string{label, a.Name, a.Namespace, a.Cluster}
+			ch <- prometheus.MustNewConstMetric(totalCost, prometheus.GaugeValue, a.TotalCost, values...)
+			ch <- prometheus.MustNewConstMetric(cpuEfficiency, prometheus.GaugeValue, a.CpuEfficiency/100, values...)
+			ch <- prometheus.MustNewConstMetric(ramEfficiency, prometheus.GaugeValue, a.RamEfficiency/100, values...)
+			ch <- prometheus.MustNewConstMetric(totalEfficiency, prometheus.GaugeValue, a.TotalEfficiency/100, values...)
+		}
+		ch <- prometheus.MustNewConstMetric(dropped, prometheus.GaugeValue, float64(s.dropped), label)
+		ch <- prometheus.MustNewConstMetric(lastCollection, prometheus.GaugeValue, float64(s.collectedAt.Unix()), label)
+	}
+}
@@ -1,22 +1,22 @@
 package namespace
 
 import (
-	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"kubecost-efficiency-fetcher/allocation"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
 	"net/http"
 	"net/url"
 	"sync"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"time"
-	"os"
 )
 
-func FetchAndWriteNamespaceData(inputURL, clusterName, window, bucketName string, wg *sync.WaitGroup) {
+func FetchAndWriteNamespaceData(inputURL, clusterName, window string, store storage.Backend, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 	u, err := url.Parse(inputURL)
@@ -68,53 +68,10 @@ func FetchAndWriteNamespaceData(inputURL, clusterName, window, bucketName string
 	data := result["data"].([]interface{})
 
 
-	svc := configs.Svc
-
-	objectKey := "Namespace/Namespace.csv"
-
-	existingData := [][]string{}
-	fileExists := false
-
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err == nil {
-
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		reader := csv.NewReader(resp.Body)
-		existingData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing CSV data:", err)
-			return
-		}
-		fileExists = true
-	} else {
-		configs.InfoLogger.Println("No existing Namespace.csv file found. A new one will be created.")
-	}
-
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
-	defer writer.Flush()
-
-
-	if !fileExists {
-		header := []string{"Namespace","ClusterName", "Region", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency"}
-		if err := writer.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to CSV:", err)
-			return
-		}
-	}
+	objectKey := configs.ObjectKey("Namespace")
 
+	records := [][]string{}
+	allocations := []allocation.Allocation{}
 
 	for _, element := range data {
 		if element == nil{
@@ -162,6 +119,8 @@ func FetchAndWriteNamespaceData(inputURL, clusterName, window, bucketName string
 			cpuEfficiency := namespaceOne["cpuEfficiency"].(float64) * 100
 			ramEfficiency := namespaceOne["ramEfficiency"].(float64) * 100
 			totalEfficiency := namespaceOne["totalEfficiency"].(float64) * 100
+			cpuWaste := allocation.Waste(cpuCost, cpuEfficiency)
+			ramWaste := allocation.Waste(ramCost, ramEfficiency)
 
 			record := []string{
 				name,clusterName, region, windowStart, windowEnd,
@@ -171,40 +130,51 @@ func FetchAndWriteNamespaceData(inputURL, clusterName, window, bucketName string
 				fmt.Sprintf("%f", totalCost),
 				fmt.Sprintf("%f", cpuEfficiency), fmt.Sprintf("%f", ramEfficiency),
 				fmt.Sprintf("%f", totalEfficiency),
+				fmt.Sprintf("%f", cpuWaste), fmt.Sprintf("%f", ramWaste),
 			}
-			existingData = append(existingData, record)
+			records = append(records, record)
+
+			alloc := allocation.Allocation{
+				Aggregation:      "Namespace",
+				Name:             name,
+				Cluster:          clusterName,
+				Region:           region,
+				Namespace:        name,
+				WindowStart:      windowStart,
+				WindowEnd:        windowEnd,
+				CpuCost:          cpuCost,
+				GpuCost:          gpuCost,
+				RamCost:          ramCost,
+				PVCost:           pvCost,
+				NetworkCost:      networkCost,
+				LoadBalancerCost: loadBalancerCost,
+				TotalCost:        totalCost,
+				CpuEfficiency:    cpuEfficiency,
+				RamEfficiency:    ramEfficiency,
+				TotalEfficiency:  totalEfficiency,
+				CpuWaste:         cpuWaste,
+				RamWaste:         ramWaste,
+				Properties:       properties,
+			}
+			allocations = append(allocations, alloc)
 		}
 	}
 
 
-	if err := writer.WriteAll(existingData); err != nil {
-		configs.ErrorLogger.Println("Error writing data to CSV:", err)
-		return
-	}
-
-	err = os.MkdirAll("Output", 0755)
+	content, err := history.Append(store, objectKey, schema.Namespace, records)
 	if err != nil {
-		configs.ErrorLogger.Println("Error creating directory:", err)
+		configs.ErrorLogger.Println("Error uploading updated CSV to storage:", err)
 		return
 	}
 
-	err = os.WriteFile("Output/Namespace.csv", buffer.Bytes(), 0644)
+	err = storage.WriteLocalCopy("Namespace.csv", content)
 	if err != nil {
 		configs.ErrorLogger.Println("Error saving file namespace.csv:", err)
 		return
 	}
 
 
-	_, err = svc.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectKey),
-		Body:        bytes.NewReader(buffer.Bytes()),
-		ContentType: aws.String("text/csv"),
-	})
-	if err != nil {
-		configs.ErrorLogger.Println("Error uploading updated CSV to S3:", err)
-		return
-	}
+	sink.Publish("Namespace", allocations)
 
-	configs.InfoLogger.Println("Namespace data successfully written to S3")
+	configs.InfoLogger.Println("Namespace data successfully written to", store)
 }
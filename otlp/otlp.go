@@ -0,0 +1,182 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/schema"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const scope = "kubecost-efficiency-fetcher"
+
+// controllerAttributes maps the kind prefix of Kubecost controller names
+// (e.g. "deployment:web") to the Kubernetes semantic convention attribute.
+var controllerAttributes = map[string]string{
+	"deployment":  "k8s.deployment.name",
+	"statefulset": "k8s.statefulset.name",
+	"daemonset":   "k8s.daemonset.name",
+	"replicaset":  "k8s.replicaset.name",
+	"job":         "k8s.job.name",
+	"cronjob":     "k8s.cronjob.name",
+}
+
+// Sink exports the cost breakdown and efficiencies of every allocation as OTLP
+// gauges. The data points cover the collected window, so they are exported
+// directly instead of being recorded through instruments.
+type Sink struct {
+	exporter sdkmetric.Exporter
+	resource *resource.Resource
+}
+
+// Open creates an exporter for endpoint, a URL such as http://localhost:4317.
+// protocol is "grpc" or "http/protobuf"; an http:// endpoint disables TLS. For
+// http/protobuf, an endpoint without a path posts to /v1/metrics.
+func Open(endpoint, protocol, cluster string, headers map[string]string) (*Sink, error) {
+	var exporter sdkmetric.Exporter
+	var err error
+	ctx := context.Background()
+	switch protocol {
+	case "grpc":
+		exporter, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(endpoint), otlpmetricgrpc.WithHeaders(headers))
+	case "http/protobuf":
+		// The HTTP exporter posts to the URL as given; default to the collector's path.
+		u, parseErr := url.Parse(endpoint)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/metrics"
+		}
+		exporter, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(u.String()), otlpmetrichttp.WithHeaders(headers))
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, use grpc or http/protobuf", protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", scope),
+		attribute.String("k8s.cluster.name", cluster),
+	)
+	return &Sink{exporter: exporter, resource: res}, nil
+}
+
+func (s *Sink) Name() string {
+	return "otlp"
+}
+
+// Attributes returns the attributes of an allocation, using the Kubernetes
+// semantic conventions where the aggregation has one.
+func Attributes(a allocation.Allocation) attribute.Set {
+	attributes := []attribute.KeyValue{
+		attribute.String("kubecost.aggregation", schema.ColumnName(a.Aggregation)),
+		attribute.String("kubecost.allocation.name", a.Name),
+		attribute.String("k8s.cluster.name", a.Cluster),
+	}
+	if a.Region != "" {
+		attributes = append(attributes, attribute.String("cloud.region", a.Region))
+	}
+	if a.Namespace != "" && a.Aggregation != "Namespace" {
+		attributes = append(attributes, attribute.String("k8s.namespace.name", a.Namespace))
+	}
+
+	switch a.Aggregation {
+	case "Namespace":
+		attributes = append(attributes, attribute.String("k8s.namespace.name", a.Name))
+	case "Node":
+		attributes = append(attributes, attribute.String("k8s.node.name", a.Name))
+	case "Pod":
+		attributes = append(attributes, attribute.String("k8s.pod.name", a.Name))
+	case "Deployment":
+		attributes = append(attributes, attribute.String("k8s.deployment.name", a.Name))
+	case "Controller":
+		if kind, name, ok := strings.Cut(a.Name, ":"); ok {
+			if key, ok := controllerAttributes[kind]; ok {
+				attributes = append(attributes, attribute.String(key, name))
+			}
+		}
+	}
+	return attribute.NewSet(attributes...)
+}
+
+// Metrics returns one gauge per cost and efficiency field, with a data point per
+// allocation. Efficiencies are ratios (0-1).
+func Metrics(allocations []allocation.Allocation) []metricdata.Metrics {
+	var metrics []metricdata.Metrics
+	for _, f := range allocation.Fields {
+		if f.Kind != allocation.Float {
+			continue
+		}
+		efficiency := strings.HasSuffix(f.Name, "_efficiency")
+
+		var points []metricdata.DataPoint[float64]
+		for _, a := range allocations {
+			start, err := time.Parse(time.RFC3339, a.WindowStart)
+			if err != nil {
+				continue
+			}
+			end, err := time.Parse(time.RFC3339, a.WindowEnd)
+			if err != nil {
+				continue
+			}
+			value := f.Value(a).(float64)
+			if efficiency {
+				value /= 100
+			}
+			points = append(points, metricdata.DataPoint[float64]{
+				Attributes: Attributes(a),
+				StartTime:  start,
+				Time:       end,
+				Value:      value,
+			})
+		}
+
+		m := metricdata.Metrics{
+			Name: "kubecost.allocation." + f.Name,
+			Data: metricdata.Gauge[float64]{DataPoints: points},
+		}
+		if efficiency {
+			m.Description = "Efficiency of the allocation over the collected window."
+			m.Unit = "1"
+		} else {
+			m.Description = "Cost of the allocation over the collected window."
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+	rm := &metricdata.ResourceMetrics{
+		Resource: s.resource,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope:   instrumentation.Scope{Name: scope},
+			Metrics: Metrics(allocations),
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.exporter.Export(ctx, rm)
+}
+
+func (s *Sink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/schema"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Sink upserts allocations into one PostgreSQL table per aggregation.
+type Sink struct {
+	db     *sql.DB
+	schema string
+
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+func Open(dsn, dbSchema string) (*Sink, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Sink{db: db, schema: dbSchema, tables: map[string]bool{}}, nil
+}
+
+func (s *Sink) Name() string {
+	return "postgres"
+}
+
+func (s *Sink) table(aggregation string) string {
+	return pgx.Identifier{s.schema, schema.ColumnName(aggregation)}.Sanitize()
+}
+
+func columnType(kind allocation.Kind) string {
+	switch kind {
+	case allocation.Float:
+		return "double precision"
+	case allocation.Time:
+		return "timestamptz"
+	}
+	return "text"
+}
+
+// migrate creates the table of an aggregation and adds any column it is missing,
+// so tables created by older versions pick up new fields.
+func (s *Sink) migrate(aggregation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables[aggregation] {
+		return nil
+	}
+
+	table := s.table(aggregation)
+	var keys, keyColumns []string
+	for _, f := range allocation.Fields[:allocation.KeyFields] {
+		keys = append(keys, pgx.Identifier{f.Name}.Sanitize())
+		keyColumns = append(keyColumns, fmt.Sprintf("%s %s NOT NULL", pgx.Identifier{f.Name}.Sanitize(), columnType(f.Kind)))
+	}
+	statements := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pgx.Identifier{s.schema}.Sanitize()),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (%s))", table, strings.Join(keyColumns, ", "), strings.Join(keys, ", ")),
+	}
+	for _, f := range allocation.Fields[allocation.KeyFields:] {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, pgx.Identifier{f.Name}.Sanitize(), columnType(f.Kind)))
+	}
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS run_id text", table),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS updated_at timestamptz", table),
+	)
+
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	s.tables[aggregation] = true
+	return nil
+}
+
+// upsertStatement inserts a row or updates the existing row with the same name, cluster, namespace and window.
+func (s *Sink) upsertStatement(aggregation string) string {
+	var columns, placeholders, updates, keys []string
+	for i, f := range allocation.Fields {
+		column := pgx.Identifier{f.Name}.Sanitize()
+		columns = append(columns, column)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		if i < allocation.KeyFields {
+			keys = append(keys, column)
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+	n := len(allocation.Fields)
+	columns = append(columns, "run_id", "updated_at")
+	placeholders = append(placeholders, fmt.Sprintf("$%d", n+1), fmt.Sprintf("$%d", n+2))
+	updates = append(updates, "run_id = EXCLUDED.run_id", "updated_at = EXCLUDED.updated_at")
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		s.table(aggregation), strings.Join(columns, ", "), strings.Join(placeholders, ", "),
+		strings.Join(keys, ", "), strings.Join(updates, ", "))
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if err := s.migrate(aggregation); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(s.upsertStatement(aggregation))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, a := range allocations {
+		args := make([]interface{}, 0, len(allocation.Fields)+2)
+		for _, f := range allocation.Fields {
+			args = append(args, f.Value(a))
+		}
+		args = append(args, configs.RunID, now)
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Sink) Close() error {
+	return s.db.Close()
+}
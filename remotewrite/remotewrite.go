@@ -0,0 +1,218 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/schema"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Config holds the settings of the remote-write client.
+type Config struct {
+	URL          string
+	Username     string // basic auth, used when set
+	Password     string
+	BearerToken  string // used when set and Username is empty
+	Labels       map[string]string
+	Aggregations []string // empty pushes all aggregations
+	BatchSize    int      // series per request
+	MaxRetries   int
+}
+
+// Label is a name/value pair of a series.
+type Label struct {
+	Name, Value string
+}
+
+// Series is one sample of a time series.
+type Series struct {
+	Labels    []Label // sorted by name
+	Value     float64
+	Timestamp time.Time
+}
+
+// Sink pushes the costs and efficiencies of every allocation to a Prometheus
+// remote-write endpoint, as samples timestamped at the end of the window.
+type Sink struct {
+	config       Config
+	aggregations map[string]bool
+	client       *http.Client
+}
+
+func Open(config Config) (*Sink, error) {
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("remote-write batch size must be positive")
+	}
+	s := &Sink{config: config, aggregations: map[string]bool{}, client: &http.Client{Timeout: 30 * time.Second}}
+	for _, aggregation := range config.Aggregations {
+		s.aggregations[aggregation] = true
+	}
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "remote-write"
+}
+
+// Series returns the samples of an allocation: one kubecost_allocation_<field>
+// series per cost and efficiency field. Efficiencies are ratios (0-1), as on /metrics.
+func (s *Sink) Series(a allocation.Allocation) []Series {
+	timestamp, err := time.Parse(time.RFC3339, a.WindowEnd)
+	if err != nil {
+		return nil
+	}
+
+	common := map[string]string{}
+	for name, value := range s.config.Labels {
+		common[name] = value
+	}
+	common["aggregation"] = schema.ColumnName(a.Aggregation)
+	common["name"] = a.Name
+	common["namespace"] = a.Namespace
+	common["cluster"] = a.Cluster
+
+	var series []Series
+	for _, f := range allocation.Fields {
+		if f.Kind != allocation.Float {
+			continue
+		}
+		value := f.Value(a).(float64)
+		if strings.HasSuffix(f.Name, "_efficiency") {
+			value /= 100
+		}
+
+		labels := []Label{{"__name__", "kubecost_allocation_" + f.Name}}
+		for name, v := range common {
+			// An empty value is the same as a missing label in Prometheus.
+			if v != "" {
+				labels = append(labels, Label{name, v})
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		series = append(series, Series{Labels: labels, Value: value, Timestamp: timestamp})
+	}
+	return series
+}
+
+// seriesKey identifies the samples of an allocation by the labels and timestamp
+// Series gives them.
+func seriesKey(a allocation.Allocation) string {
+	return a.Name + "\x00" + a.Namespace + "\x00" + a.Cluster + "\x00" + a.WindowEnd
+}
+
+// Encode returns the snappy-compressed protobuf WriteRequest of the series.
+func Encode(series []Series) []byte {
+	var request []byte
+	for _, ts := range series {
+		var timeSeries []byte
+		for _, l := range ts.Labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.Name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.Value)
+
+			timeSeries = protowire.AppendTag(timeSeries, 1, protowire.BytesType)
+			timeSeries = protowire.AppendBytes(timeSeries, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(ts.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(ts.Timestamp.UnixMilli()))
+
+		timeSeries = protowire.AppendTag(timeSeries, 2, protowire.BytesType)
+		timeSeries = protowire.AppendBytes(timeSeries, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, timeSeries)
+	}
+	return snappy.Encode(nil, request)
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	if len(s.aggregations) > 0 && !s.aggregations[aggregation] {
+		return nil
+	}
+
+	// Samples with the same labels and timestamp are rejected as duplicates, so
+	// allocations that share a series are summed first.
+	var series []Series
+	for _, a := range allocation.MergeBy(allocations, seriesKey) {
+		series = append(series, s.Series(a)...)
+	}
+	for start := 0; start < len(series); start += s.config.BatchSize {
+		end := start + s.config.BatchSize
+		if end > len(series) {
+			end = len(series)
+		}
+		if err := s.push(Encode(series[start:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// push sends one request. Network errors, 429 and 5xx responses are retried with
+// an exponential backoff; other responses mean the data was rejected.
+func (s *Sink) push(body []byte) error {
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = s.send(body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d retries: %w", s.config.MaxRetries, err)
+}
+
+func (s *Sink) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	} else if s.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.BearerToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	err = fmt.Errorf("remote write returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
+
+func (s *Sink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
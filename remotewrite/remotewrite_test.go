@@ -0,0 +1,185 @@
+package remotewrite
+
+import (
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decode parses a WriteRequest into one map of labels per series, with the
+// sample value under "value".
+func decode(t *testing.T, body []byte) []map[string]interface{} {
+	t.Helper()
+	request, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series []map[string]interface{}
+	for len(request) > 0 {
+		_, _, n := protowire.ConsumeTag(request)
+		timeSeries, m := protowire.ConsumeBytes(request[n:])
+		request = request[n+m:]
+
+		s := map[string]interface{}{}
+		for len(timeSeries) > 0 {
+			field, _, n := protowire.ConsumeTag(timeSeries)
+			message, m := protowire.ConsumeBytes(timeSeries[n:])
+			timeSeries = timeSeries[n+m:]
+			switch field {
+			case 1:
+				_, _, n := protowire.ConsumeTag(message)
+				name, m := protowire.ConsumeString(message[n:])
+				message = message[n+m:]
+				_, _, n = protowire.ConsumeTag(message)
+				value, _ := protowire.ConsumeString(message[n:])
+				s[name] = value
+			case 2:
+				_, _, n := protowire.ConsumeTag(message)
+				bits, _ := protowire.ConsumeFixed64(message[n:])
+				s["value"] = math.Float64frombits(bits)
+			}
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+type endpoint struct {
+	mu       sync.Mutex
+	statuses []int
+	requests [][]map[string]interface{}
+	t        *testing.T
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") == "" {
+		http.Error(w, "not a remote-write request", http.StatusBadRequest)
+		return
+	}
+	if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	e.requests = append(e.requests, decode(e.t, body))
+	if len(e.statuses) > 0 {
+		status := e.statuses[0]
+		e.statuses = e.statuses[1:]
+		http.Error(w, "rejected", status)
+	}
+}
+
+func open(t *testing.T, url string, batchSize, maxRetries int) *Sink {
+	t.Helper()
+	s, err := Open(Config{
+		URL:        url,
+		Username:   "user",
+		Password:   "password",
+		Labels:     map[string]string{"source": "test"},
+		BatchSize:  batchSize,
+		MaxRetries: maxRetries,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func pod(name string, cost float64) allocation.Allocation {
+	return allocation.Allocation{
+		Aggregation:     "Pod",
+		Name:            name,
+		Cluster:         "prod",
+		Namespace:       "payments",
+		WindowStart:     "2024-10-01T00:00:00Z",
+		WindowEnd:       "2024-10-02T00:00:00Z",
+		TotalCost:       cost,
+		TotalEfficiency: 50,
+	}
+}
+
+func TestWrite(t *testing.T) {
+	e := &endpoint{t: t}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	s := open(t, server.URL, 1000, 0)
+	// The two rows of api share their series and are summed.
+	if err := s.Write("Pod", []allocation.Allocation{pod("api", 1), pod("api", 2), pod("worker", 4)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(e.requests))
+	}
+
+	totals := map[string]float64{}
+	for _, series := range e.requests[0] {
+		if series["source"] != "test" || series["cluster"] != "prod" || series["aggregation"] != "pod" {
+			t.Errorf("labels %v", series)
+		}
+		switch series["__name__"] {
+		case "kubecost_allocation_total_cost":
+			totals[series["name"].(string)] = series["value"].(float64)
+		case "kubecost_allocation_total_efficiency":
+			if series["value"] != 0.5 {
+				t.Errorf("efficiency %v, want the ratio 0.5", series["value"])
+			}
+		}
+	}
+	if totals["api"] != 3 || totals["worker"] != 4 {
+		t.Errorf("total costs %v", totals)
+	}
+}
+
+func TestWriteBatches(t *testing.T) {
+	e := &endpoint{t: t}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	s := open(t, server.URL, 5, 0)
+	series := len(s.Series(pod("api", 1)))
+	if err := s.Write("Pod", []allocation.Allocation{pod("api", 1), pod("worker", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	want := (2*series + 4) / 5
+	if len(e.requests) != want {
+		t.Errorf("got %d requests, want %d", len(e.requests), want)
+	}
+}
+
+func TestWriteRetries(t *testing.T) {
+	e := &endpoint{t: t, statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	if err := open(t, server.URL, 1000, 1).Write("Pod", []allocation.Allocation{pod("api", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(e.requests))
+	}
+}
+
+func TestWriteRejected(t *testing.T) {
+	e := &endpoint{t: t, statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	err := open(t, server.URL, 1000, 3).Write("Pod", []allocation.Allocation{pod("api", 1)})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("got %v, want the rejection", err)
+	}
+	if len(e.requests) != 1 {
+		t.Errorf("rejected request was retried: %d requests", len(e.requests))
+	}
+}
@@ -0,0 +1,155 @@
+package report
+
+import (
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/storage"
+	"sort"
+	"time"
+)
+
+// Line is the cost of one namespace or deployment over a period. Efficiency is
+// the cost-weighted average of the daily efficiencies, in percent.
+type Line struct {
+	Name       string
+	Namespace  string
+	Cost       float64
+	Efficiency float64
+	Waste      float64 // CPU and RAM waste, see allocation.Waste
+}
+
+// Day is the cluster cost of one Window.
+type Day struct {
+	Start      time.Time
+	Cost       float64
+	Efficiency float64
+}
+
+// Summary is the cost of a cluster over a period, built from the stored history.
+type Summary struct {
+	Cluster    string
+	Start, End time.Time
+
+	TotalCost  float64
+	Efficiency float64
+	Daily      []Day // days with Cluster data, oldest first
+
+	Namespaces  []Line // by cost, most expensive first
+	Deployments []Line // by cost, most expensive first
+}
+
+// Build summarises the Cluster, Namespace and Deployment rows of cluster in the
+// Windows between start and end.
+func Build(store storage.Backend, cluster string, start, end time.Time) (*Summary, error) {
+	s := &Summary{Cluster: cluster, Start: start, End: end}
+
+	loaded := map[string][]allocation.Allocation{}
+	for _, aggregation := range []string{"Cluster", "Namespace", "Deployment"} {
+		allocations, err := history.Load(store, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		loaded[aggregation] = OfCluster(InPeriod(allocations, start, end), cluster)
+	}
+
+	days := map[time.Time]*Day{}
+	var weighted float64
+	for _, a := range loaded["Cluster"] {
+		day := windowStart(a)
+		d, ok := days[day]
+		if !ok {
+			d = &Day{Start: day}
+			days[day] = d
+		}
+		d.Cost += a.TotalCost
+		d.Efficiency += a.TotalCost * a.TotalEfficiency
+		s.TotalCost += a.TotalCost
+		weighted += a.TotalCost * a.TotalEfficiency
+	}
+	if s.TotalCost > 0 {
+		s.Efficiency = weighted / s.TotalCost
+	}
+	for _, d := range days {
+		if d.Cost > 0 {
+			d.Efficiency /= d.Cost
+		}
+		s.Daily = append(s.Daily, *d)
+	}
+	sort.Slice(s.Daily, func(i, j int) bool { return s.Daily[i].Start.Before(s.Daily[j].Start) })
+
+	s.Namespaces = Lines(loaded["Namespace"])
+	s.Deployments = Lines(loaded["Deployment"])
+	return s, nil
+}
+
+// InPeriod returns the allocations whose Window lies between start and end.
+func InPeriod(allocations []allocation.Allocation, start, end time.Time) []allocation.Allocation {
+	var kept []allocation.Allocation
+	for _, a := range allocations {
+		windowEnd, err := time.Parse(time.RFC3339, a.WindowEnd)
+		if err != nil {
+			continue
+		}
+		if !windowStart(a).Before(start) && !windowEnd.After(end) {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// OfCluster returns the allocations of cluster. The history of a storage backend
+// shared by several clusters holds the rows of all of them.
+func OfCluster(allocations []allocation.Allocation, cluster string) []allocation.Allocation {
+	var kept []allocation.Allocation
+	for _, a := range allocations {
+		if a.Cluster == cluster {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func windowStart(a allocation.Allocation) time.Time {
+	t, _ := time.Parse(time.RFC3339, a.WindowStart)
+	return t
+}
+
+// Lines sums the allocations by namespace and name, most expensive first.
+func Lines(allocations []allocation.Allocation) []Line {
+	byName := map[[2]string]*Line{}
+	var order [][2]string
+	for _, a := range allocations {
+		key := [2]string{a.Namespace, a.Name}
+		l, ok := byName[key]
+		if !ok {
+			l = &Line{Name: a.Name, Namespace: a.Namespace}
+			byName[key] = l
+			order = append(order, key)
+		}
+		l.Cost += a.TotalCost
+		l.Efficiency += a.TotalCost * a.TotalEfficiency
+		l.Waste += a.CpuWaste + a.RamWaste
+	}
+
+	lines := make([]Line, 0, len(order))
+	for _, key := range order {
+		l := byName[key]
+		if l.Cost > 0 {
+			l.Efficiency /= l.Cost
+		}
+		lines = append(lines, *l)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Cost > lines[j].Cost })
+	return lines
+}
+
+// DeploymentsOf returns the deployments of a namespace, most expensive first.
+func (s *Summary) DeploymentsOf(namespace string) []Line {
+	var lines []Line
+	for _, l := range s.Deployments {
+		if l.Namespace == namespace {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,172 @@
+package rightsizing
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"math"
+	"sort"
+	"time"
+)
+
+// hoursPerMonth is the average number of hours in a month, as used by Kubecost.
+const hoursPerMonth = 730
+
+// Options configure how recommendations are computed.
+type Options struct {
+	// Percentile of the daily usage averages that is sized for, e.g. 95. 100 sizes
+	// for the busiest day.
+	Percentile float64
+	// TargetUtilization is the share of the request the usage should fill, e.g. 0.8.
+	TargetUtilization float64
+	// Headroom is added on top of the usage, e.g. 0.1 for 10%.
+	Headroom float64
+	// Minimum recommended requests.
+	MinCPUCores float64
+	MinRAMBytes float64
+}
+
+// Recommendation is the recommended CPU and RAM request of one deployment,
+// controller or rollout. Requests and usage are the totals of all of its pods.
+type Recommendation struct {
+	Aggregation string
+	Cluster     string
+	Namespace   string
+	Name        string
+	Days        int // windows with request data
+
+	CPURequest, CPUUsage, CPURecommended float64 // cores
+	RAMRequest, RAMUsage, RAMRecommended float64 // bytes
+
+	// Estimated monthly savings of the recommended requests at the prices of the
+	// period. They are negative when the recommendation is an increase.
+	CPUSavings, RAMSavings float64
+}
+
+// Savings is the estimated monthly saving of both requests.
+func (r Recommendation) Savings() float64 {
+	return r.CPUSavings + r.RAMSavings
+}
+
+// Recommend computes one recommendation per cluster, namespace and name of the allocations,
+// which must all be of the same aggregation. Rows collected before the averages
+// were added have no request data and are skipped. The current request is the one
+// of the most recent window. Recommendations are sorted by savings, largest first.
+func Recommend(allocations []allocation.Allocation, o Options) []Recommendation {
+	byName := map[[3]string][]allocation.Allocation{}
+	var order [][3]string
+	for _, a := range allocations {
+		if a.CpuCoreRequestAverage == 0 && a.RamBytesRequestAverage == 0 && a.CpuCoreUsageAverage == 0 && a.RamBytesUsageAverage == 0 {
+			continue
+		}
+		key := [3]string{a.Cluster, a.Namespace, a.Name}
+		if _, ok := byName[key]; !ok {
+			order = append(order, key)
+		}
+		byName[key] = append(byName[key], a)
+	}
+
+	recommendations := make([]Recommendation, 0, len(order))
+	for _, key := range order {
+		rows := byName[key]
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].WindowStart < rows[j].WindowStart })
+		latest := rows[len(rows)-1]
+
+		r := Recommendation{
+			Aggregation: latest.Aggregation,
+			Cluster:     key[0],
+			Namespace:   key[1],
+			Name:        key[2],
+			Days:        len(rows),
+			CPURequest:  latest.CpuCoreRequestAverage,
+			RAMRequest:  latest.RamBytesRequestAverage,
+		}
+		var cpuUsage, ramUsage []float64
+		var cpuCost, ramCost, cpuHours, ramHours float64
+		for _, a := range rows {
+			cpuUsage = append(cpuUsage, a.CpuCoreUsageAverage)
+			ramUsage = append(ramUsage, a.RamBytesUsageAverage)
+
+			// Kubecost charges the larger of request and usage, which gives the
+			// price per core-hour and byte-hour of the period.
+			hours := windowHours(a)
+			cpuCost += a.CpuCost
+			cpuHours += math.Max(a.CpuCoreRequestAverage, a.CpuCoreUsageAverage) * hours
+			ramCost += a.RamCost
+			ramHours += math.Max(a.RamBytesRequestAverage, a.RamBytesUsageAverage) * hours
+		}
+		r.CPUUsage = percentile(cpuUsage, o.Percentile)
+		r.RAMUsage = percentile(ramUsage, o.Percentile)
+		r.CPURecommended = recommend(r.CPUUsage, o, o.MinCPUCores)
+		r.RAMRecommended = recommend(r.RAMUsage, o, o.MinRAMBytes)
+		if cpuHours > 0 {
+			r.CPUSavings = (r.CPURequest - r.CPURecommended) * cpuCost / cpuHours * hoursPerMonth
+		}
+		if ramHours > 0 {
+			r.RAMSavings = (r.RAMRequest - r.RAMRecommended) * ramCost / ramHours * hoursPerMonth
+		}
+		recommendations = append(recommendations, r)
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool { return recommendations[i].Savings() > recommendations[j].Savings() })
+	return recommendations
+}
+
+// recommend sizes a request so that usage plus headroom fills the target utilization.
+func recommend(usage float64, o Options, min float64) float64 {
+	request := usage * (1 + o.Headroom)
+	if o.TargetUtilization > 0 {
+		request /= o.TargetUtilization
+	}
+	return math.Max(request, min)
+}
+
+// percentile returns the p-th percentile of values using the nearest-rank method.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func windowHours(a allocation.Allocation) float64 {
+	start, err := time.Parse(time.RFC3339, a.WindowStart)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse(time.RFC3339, a.WindowEnd)
+	if err != nil {
+		return 0
+	}
+	return end.Sub(start).Hours()
+}
+
+// Header is the header of the recommendations CSV.
+var Header = []string{
+	"Aggregation", "ClusterName", "Namespace", "Name", "Days",
+	"Cpu Request (cores)", "Cpu Usage (cores)", "Recommended Cpu Request (cores)",
+	"Ram Request (MiB)", "Ram Usage (MiB)", "Recommended Ram Request (MiB)",
+	"Cpu Monthly Savings", "Ram Monthly Savings", "Monthly Savings",
+}
+
+// Records returns the recommendations as CSV records, without the header.
+func Records(recommendations []Recommendation) [][]string {
+	const mib = 1 << 20
+	records := make([][]string, 0, len(recommendations))
+	for _, r := range recommendations {
+		records = append(records, []string{
+			r.Aggregation, r.Cluster, r.Namespace, r.Name, fmt.Sprint(r.Days),
+			fmt.Sprintf("%.3f", r.CPURequest), fmt.Sprintf("%.3f", r.CPUUsage), fmt.Sprintf("%.3f", r.CPURecommended),
+			fmt.Sprintf("%.0f", r.RAMRequest/mib), fmt.Sprintf("%.0f", r.RAMUsage/mib), fmt.Sprintf("%.0f", r.RAMRecommended/mib),
+			fmt.Sprintf("%f", r.CPUSavings), fmt.Sprintf("%f", r.RAMSavings), fmt.Sprintf("%f", r.Savings()),
+		})
+	}
+	return records
+}
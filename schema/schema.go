@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Version identifies the set of headers below. It is recorded in the run manifest
+// and must be incremented whenever a header changes.
+const Version = 3
+
+// Column headers written to the CSV object of each aggregation. Collectors write
+// their records in this order, and the Athena table definitions are derived from
+// the same lists so both stay in sync when a column is added.
+var (
+	Cluster = []string{"Cluster", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Waste", "Ram Waste"}
+
+	Node = []string{
+		"Node", "ClusterName", "Region", "Window Start", "Window End",
+		"Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost",
+		"LoadBalancer Cost", "Total Cost",
+		"Cpu Efficiency", "Ram Efficiency", "Total Efficiency",
+		"Cpu Waste", "Ram Waste",
+	}
+
+	Pod = []string{
+		"Pod", "ClusterName", "Region", "Namespace", "Window Start", "Window End",
+		"Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost",
+		"LoadBalancer Cost", "Total Cost", "Cpu Efficiency",
+		"Ram Efficiency", "Total Efficiency",
+		"Cpu Waste", "Ram Waste",
+	}
+
+	Namespace = []string{"Namespace", "ClusterName", "Region", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Waste", "Ram Waste"}
+
+	Service = []string{
+		"Service", "ClusterName", "Region", "Namespace", "Window Start", "Window End",
+		"Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost",
+		"LoadBalancer Cost", "Total Cost", "Cpu Efficiency",
+		"Ram Efficiency", "Total Efficiency",
+		"Cpu Waste", "Ram Waste",
+	}
+
+	Deployment = []string{
+		"Deployment", "ClusterName", "Region", "Namespace",
+		"Window Start", "Window End", "Cpu Cost", "Gpu Cost",
+		"Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost",
+		"Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency",
+		"Cpu Request Average", "Cpu Usage Average", "Ram Request Average", "Ram Usage Average",
+		"Cpu Waste", "Ram Waste",
+	}
+
+	Controller = []string{"Controller", "ClusterName", "Region", "Namespace", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Request Average", "Cpu Usage Average", "Ram Request Average", "Ram Usage Average", "Cpu Waste", "Ram Waste"}
+
+	Rollout = []string{"Rollout", "ClusterName", "Region", "Namespace", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Request Average", "Cpu Usage Average", "Ram Request Average", "Ram Usage Average", "Cpu Waste", "Ram Waste"}
+
+	ControllerKind = []string{"ControllerKind", "ClusterName", "Region", "Window Start", "Window End", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Waste", "Ram Waste"}
+)
+
+// Aggregations lists every output in the order the collectors are started.
+// Rollout is written by the controller collector.
+var Aggregations = []string{"Cluster", "Node", "Pod", "Namespace", "Service", "Deployment", "Controller", "Rollout", "ControllerKind"}
+
+// Header returns the column header of the given aggregation, or nil if it is unknown.
+func Header(aggregation string) []string {
+	switch aggregation {
+	case "Cluster":
+		return Cluster
+	case "Node":
+		return Node
+	case "Pod":
+		return Pod
+	case "Namespace":
+		return Namespace
+	case "Service":
+		return Service
+	case "Deployment":
+		return Deployment
+	case "Controller":
+		return Controller
+	case "Rollout":
+		return Rollout
+	case "ControllerKind":
+		return ControllerKind
+	}
+	return nil
+}
+
+// ColumnName converts a CSV header such as "LoadBalancer Cost" or "ClusterName"
+// to a snake_case Athena column name ("load_balancer_cost", "cluster_name").
+func ColumnName(header string) string {
+	var b strings.Builder
+	runes := []rune(header)
+	for i, r := range runes {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			prevLower := i > 0 && unicode.IsLower(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])
+			if (prevLower || nextLower) && !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
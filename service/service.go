@@ -1,24 +1,24 @@
 package service
 
 import (
-	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"kubecost-efficiency-fetcher/allocation"
 	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/history"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/sink"
+	"kubecost-efficiency-fetcher/storage"
 	"net/http"
 	"net/url"
 	"sync"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"time"
-	"os"
 )
 
-func FetchAndWriteServiceData(inputURL, clusterName, window, bucketName, region string, wg *sync.WaitGroup) {
+func FetchAndWriteServiceData(inputURL, clusterName, window, region string, store storage.Backend, wg *sync.WaitGroup) {
 
-	wg.Done()
+	defer wg.Done()
 	u, err := url.Parse(inputURL)
 	if err != nil {
 		configs.ErrorLogger.Println("Error parsing URL:", err)
@@ -64,55 +64,10 @@ func FetchAndWriteServiceData(inputURL, clusterName, window, bucketName, region
 
 	data := result["data"].([]interface{})
 
-	svc := configs.Svc
-
-	objectKey := "Service/Service.csv"
-
-	existingData := [][]string{}
-	fileExists := false
-
-	_, err = svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err == nil {
-		resp, err := svc.GetObject(&s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-		})
-		if err != nil {
-			configs.ErrorLogger.Println("Error fetching existing file from S3:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		reader := csv.NewReader(resp.Body)
-		existingData, err = reader.ReadAll()
-		if err != nil {
-			configs.ErrorLogger.Println("Error reading existing CSV data:", err)
-			return
-		}
-		fileExists = true
-	} else {
-		configs.InfoLogger.Println("No existing Service CSV file found. A new one will be created.")
-	}
+	objectKey := configs.ObjectKey("Service")
 
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
-	defer writer.Flush()
-
-	if !fileExists {
-		header := []string{
-			"Service","ClusterName", "Region", "Namespace", "Window Start", "Window End",
-			"Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost",
-			"LoadBalancer Cost", "Total Cost", "Cpu Efficiency",
-			"Ram Efficiency", "Total Efficiency",
-		}
-		if err := writer.Write(header); err != nil {
-			configs.ErrorLogger.Println("Error writing header to CSV:", err)
-			return
-		}
-	}
+	records := [][]string{}
+	allocations := []allocation.Allocation{}
 
 	for _, element := range data {
 		if element == nil{
@@ -166,6 +121,8 @@ func FetchAndWriteServiceData(inputURL, clusterName, window, bucketName, region
 			cpuEfficiency := serviceOne["cpuEfficiency"].(float64) * 100
 			ramEfficiency := serviceOne["ramEfficiency"].(float64) * 100
 			totalEfficiency := serviceOne["totalEfficiency"].(float64) * 100
+			cpuWaste := allocation.Waste(cpuCost, cpuEfficiency)
+			ramWaste := allocation.Waste(ramCost, ramEfficiency)
 
 			record := []string{
 				name,clusterName ,region, namespaceService, windowStart, windowEnd,
@@ -175,39 +132,50 @@ func FetchAndWriteServiceData(inputURL, clusterName, window, bucketName, region
 				fmt.Sprintf("%f", totalCost),
 				fmt.Sprintf("%f", cpuEfficiency), fmt.Sprintf("%f", ramEfficiency),
 				fmt.Sprintf("%f", totalEfficiency),
+				fmt.Sprintf("%f", cpuWaste), fmt.Sprintf("%f", ramWaste),
 			}
-			existingData = append(existingData, record)
+			records = append(records, record)
+
+			alloc := allocation.Allocation{
+				Aggregation:      "Service",
+				Name:             name,
+				Cluster:          clusterName,
+				Region:           region,
+				Namespace:        namespaceService,
+				WindowStart:      windowStart,
+				WindowEnd:        windowEnd,
+				CpuCost:          cpuCost,
+				GpuCost:          gpuCost,
+				RamCost:          ramCost,
+				PVCost:           pvCost,
+				NetworkCost:      networkCost,
+				LoadBalancerCost: loadBalancerCost,
+				TotalCost:        totalCost,
+				CpuEfficiency:    cpuEfficiency,
+				RamEfficiency:    ramEfficiency,
+				TotalEfficiency:  totalEfficiency,
+				CpuWaste:         cpuWaste,
+				RamWaste:         ramWaste,
+				Properties:       properties,
+			}
+			allocations = append(allocations, alloc)
 		}
 	}
 
-	if err := writer.WriteAll(existingData); err != nil {
-		configs.ErrorLogger.Println("Error writing data to CSV:", err)
-		return
-	}
-
-	err = os.MkdirAll("Output", 0755)
+	content, err := history.Append(store, objectKey, schema.Service, records)
 	if err != nil {
-		configs.ErrorLogger.Println("Error creating directory:", err)
+		configs.ErrorLogger.Println("Error uploading updated CSV to storage:", err)
 		return
 	}
 
-	err = os.WriteFile("Output/Service.csv", buffer.Bytes(), 0644)
+	err = storage.WriteLocalCopy("Service.csv", content)
 	if err != nil {
 		configs.ErrorLogger.Println("Error saving file service.csv:", err)
 		return
 	}
 
 
-	_, err = svc.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectKey),
-		Body:        bytes.NewReader(buffer.Bytes()),
-		ContentType: aws.String("text/csv"),
-	})
-	if err != nil {
-		configs.ErrorLogger.Println("Error uploading updated CSV to S3:", err)
-		return
-	}
+	sink.Publish("Service", allocations)
 
-	configs.InfoLogger.Println("Service data successfully written to S3")
+	configs.InfoLogger.Println("Service data successfully written to", store)
 }
@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"kubecost-efficiency-fetcher/clickhouse"
+	"kubecost-efficiency-fetcher/configs"
+	"kubecost-efficiency-fetcher/kafka"
+	"kubecost-efficiency-fetcher/otlp"
+	"kubecost-efficiency-fetcher/postgres"
+	"kubecost-efficiency-fetcher/remotewrite"
+	"kubecost-efficiency-fetcher/sqlite"
+	"kubecost-efficiency-fetcher/webhook"
+)
+
+// openers create the sinks. Each returns a nil Sink when it is disabled.
+var openers = []func() (Sink, error){
+	openPostgres,
+	openSQLite,
+	openClickHouse,
+	openKafka,
+	openRemoteWrite,
+	openOTLP,
+	openWebhook,
+}
+
+func openPostgres() (Sink, error) {
+	if configs.PostgresDSN == "" {
+		return nil, nil
+	}
+	s, err := postgres.Open(configs.PostgresDSN, configs.PostgresSchema)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openSQLite() (Sink, error) {
+	if configs.SQLitePath == "" {
+		return nil, nil
+	}
+	s, err := sqlite.Open(configs.SQLitePath)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openClickHouse() (Sink, error) {
+	if configs.ClickHouseEndpoint == "" {
+		return nil, nil
+	}
+	s, err := clickhouse.Open(configs.ClickHouseEndpoint, configs.ClickHouseDatabase, configs.ClickHouseUser, configs.ClickHousePassword, configs.ClickHouseAggregations)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openKafka() (Sink, error) {
+	if len(configs.KafkaBrokers) == 0 {
+		return nil, nil
+	}
+	s, err := kafka.Open(kafka.Config{
+		Brokers:      configs.KafkaBrokers,
+		TopicPrefix:  configs.KafkaTopicPrefix,
+		Topics:       configs.KafkaTopics,
+		RequiredAcks: configs.KafkaRequiredAcks,
+		BatchSize:    configs.KafkaBatchSize,
+		BatchTimeout: configs.KafkaBatchTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openRemoteWrite() (Sink, error) {
+	if configs.RemoteWriteURL == "" {
+		return nil, nil
+	}
+	s, err := remotewrite.Open(remotewrite.Config{
+		URL:          configs.RemoteWriteURL,
+		Username:     configs.RemoteWriteUsername,
+		Password:     configs.RemoteWritePassword,
+		BearerToken:  configs.RemoteWriteBearerToken,
+		Labels:       configs.RemoteWriteLabels,
+		Aggregations: configs.RemoteWriteAggregations,
+		BatchSize:    configs.RemoteWriteBatchSize,
+		MaxRetries:   configs.RemoteWriteMaxRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openOTLP() (Sink, error) {
+	if configs.OTLPEndpoint == "" {
+		return nil, nil
+	}
+	s, err := otlp.Open(configs.OTLPEndpoint, configs.OTLPProtocol, configs.ClusterName, configs.OTLPHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openWebhook() (Sink, error) {
+	if len(configs.WebhookURLs) == 0 {
+		return nil, nil
+	}
+	s, err := webhook.Open(webhook.Config{
+		URLs:           configs.WebhookURLs,
+		Secret:         configs.WebhookSecret,
+		SendRecords:    configs.WebhookSendRecords,
+		SendSummary:    configs.WebhookSendSummary,
+		BatchSize:      configs.WebhookBatchSize,
+		MaxRetries:     configs.WebhookMaxRetries,
+		DeadLetterFile: configs.WebhookDeadLetterFile,
+		RunID:          configs.RunID,
+		Cluster:        configs.ClusterName,
+		Window:         configs.Window,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
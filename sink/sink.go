@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"sync"
+)
+
+// Sink receives the allocations of every aggregation after they have been
+// written to the storage backend.
+type Sink interface {
+	Name() string
+	Write(aggregation string, allocations []allocation.Allocation) error
+	Close() error
+}
+
+var (
+	mu    sync.Mutex
+	sinks []Sink
+)
+
+// Open creates the sinks enabled in the configuration. Sinks that cannot be
+// created are logged and skipped, so the CSV history is still written.
+func Open() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, open := range openers {
+		s, err := open()
+		if err != nil {
+			configs.ErrorLogger.Println("Error opening sink:", err)
+			continue
+		}
+		if s != nil {
+			configs.InfoLogger.Println("Sink enabled:", s.Name())
+			sinks = append(sinks, s)
+		}
+	}
+}
+
+// Add registers a sink that is not created from the configuration, such as the
+// Prometheus exporter of the serve command. Like the others, it is removed by Close.
+func Add(s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	sinks = append(sinks, s)
+}
+
+// Publish hands the allocations of an aggregation to every open sink. Collectors
+// call it concurrently, so sinks must be safe for concurrent use.
+func Publish(aggregation string, allocations []allocation.Allocation) {
+	mu.Lock()
+	active := append([]Sink{}, sinks...)
+	mu.Unlock()
+
+	for _, s := range active {
+		if err := s.Write(aggregation, allocations); err != nil {
+			configs.ErrorLogger.Printf("Error writing %s data to %s: %v\n", aggregation, s.Name(), err)
+		}
+	}
+}
+
+// Close flushes and closes every open sink.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			configs.ErrorLogger.Printf("Error closing %s: %v\n", s.Name(), err)
+		}
+	}
+	sinks = nil
+}
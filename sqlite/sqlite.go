@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/configs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Sink accumulates the allocations of every aggregation in a single table of a
+// local SQLite database. It uses a pure-Go driver, so it needs no cgo.
+type Sink struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+const table = "allocations"
+
+func Open(path string) (*Sink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; collectors publish concurrently.
+	db.SetMaxOpenConns(1)
+
+	s := &Sink{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "sqlite"
+}
+
+func columnType(kind allocation.Kind) string {
+	if kind == allocation.Float {
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// migrate creates the table and its indexes and adds any column it is missing.
+func (s *Sink) migrate() error {
+	keys := []string{"aggregation"}
+	columns := []string{"aggregation TEXT NOT NULL"}
+	for _, f := range allocation.Fields[:allocation.KeyFields] {
+		keys = append(keys, f.Name)
+		columns = append(columns, fmt.Sprintf("%s %s NOT NULL", f.Name, columnType(f.Kind)))
+	}
+	statements := []string{
+		"PRAGMA journal_mode = WAL",
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (%s))", table, strings.Join(columns, ", "), strings.Join(keys, ", ")),
+	}
+
+	existing := map[string]bool{}
+	rows, err := s.db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, f := range allocation.Fields[allocation.KeyFields:] {
+		if !existing[f.Name] {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, f.Name, columnType(f.Kind)))
+		}
+	}
+	for _, column := range []string{"run_id", "updated_at"} {
+		if !existing[column] {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT", table, column))
+		}
+	}
+	for _, column := range []string{"name", "namespace", "cluster", "window_start"} {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_%s ON %s (%s)", table, column, table, column))
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// upsertStatement inserts a row or replaces the row with the same aggregation, name, cluster, namespace and window.
+func upsertStatement() string {
+	columns := []string{"aggregation"}
+	var updates []string
+	for i, f := range allocation.Fields {
+		columns = append(columns, f.Name)
+		if i >= allocation.KeyFields {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", f.Name, f.Name))
+		}
+	}
+	columns = append(columns, "run_id", "updated_at")
+	updates = append(updates, "run_id = excluded.run_id", "updated_at = excluded.updated_at")
+
+	keys := append([]string{"aggregation"}, columns[1:1+allocation.KeyFields]...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		table, strings.Join(columns, ", "), placeholders, strings.Join(keys, ", "), strings.Join(updates, ", "))
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsertStatement())
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, a := range allocations {
+		args := []interface{}{aggregation}
+		for _, f := range allocation.Fields {
+			value := f.Value(a)
+			if t, ok := value.(time.Time); ok {
+				value = t.Format(time.RFC3339)
+			}
+			args = append(args, value)
+		}
+		args = append(args, configs.RunID, now)
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Sink) Close() error {
+	return s.db.Close()
+}
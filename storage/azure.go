@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// Azure stores objects as block blobs in an Azure Blob Storage container. The
+// version of an object is its ETag.
+type Azure struct {
+	container *container.Client
+	name      string
+}
+
+// NewAzure creates an Azure backend from a storage account connection string,
+// which also covers the Azurite emulator.
+func NewAzure(containerName, connectionString string) (*Azure, error) {
+	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Azure{container: client.ServiceClient().NewContainerClient(containerName), name: containerName}, nil
+}
+
+func (b *Azure) Read(key string) (*Object, error) {
+	resp, err := b.container.NewBlobClient(key).DownloadStream(context.Background(), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	var version string
+	if resp.ETag != nil {
+		version = string(*resp.ETag)
+	}
+	return &Object{Data: buf.Bytes(), Version: version}, nil
+}
+
+func (b *Azure) Write(key string, data []byte, contentType string) error {
+	return b.upload(key, data, contentType, nil)
+}
+
+func (b *Azure) WriteIf(key string, data []byte, contentType, version string) error {
+	conditions := &blob.ModifiedAccessConditions{}
+	if version == "" {
+		etag := azcore.ETagAny
+		conditions.IfNoneMatch = &etag
+	} else {
+		etag := azcore.ETag(version)
+		conditions.IfMatch = &etag
+	}
+
+	err := b.upload(key, data, contentType, &blob.AccessConditions{ModifiedAccessConditions: conditions})
+	if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+func (b *Azure) upload(key string, data []byte, contentType string, conditions *blob.AccessConditions) error {
+	_, err := b.container.NewBlockBlobClient(key).Upload(context.Background(),
+		streaming.NopCloser(bytes.NewReader(data)),
+		&blockblob.UploadOptions{
+			HTTPHeaders:      &blob.HTTPHeaders{BlobContentType: &contentType},
+			AccessConditions: conditions,
+		})
+	return err
+}
+
+func (b *Azure) List(prefix string) ([]string, error) {
+	var keys []string
+	pager := b.container.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name != nil {
+				keys = append(keys, *item.Name)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (b *Azure) Delete(key string) error {
+	_, err := b.container.NewBlobClient(key).Delete(context.Background(), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (b *Azure) DeleteIf(key, version string) error {
+	etag := azcore.ETag(version)
+	_, err := b.container.NewBlobClient(key).Delete(context.Background(), &blob.DeleteOptions{
+		AccessConditions: &blob.AccessConditions{ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: &etag}},
+	})
+	switch {
+	case bloberror.HasCode(err, bloberror.BlobNotFound):
+		return nil
+	case bloberror.HasCode(err, bloberror.ConditionNotMet):
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+func (b *Azure) String() string {
+	return "azure://" + b.name
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCS stores objects in a Google Cloud Storage bucket. The version of an object
+// is its generation number.
+type GCS struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCS creates a GCS backend using Application Default Credentials. When endpoint
+// is set (e.g. a fake-gcs-server emulator) requests are sent there unauthenticated.
+func NewGCS(bucket, endpoint string) (*GCS, error) {
+	var opts []option.ClientOption
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GCS{client: client, bucket: bucket}, nil
+}
+
+func (b *GCS) Read(key string) (*Object, error) {
+	r, err := b.client.Bucket(b.bucket).Object(key).NewReader(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, Version: strconv.FormatInt(r.Attrs.Generation, 10)}, nil
+}
+
+func (b *GCS) Write(key string, data []byte, contentType string) error {
+	return b.write(b.client.Bucket(b.bucket).Object(key), data, contentType)
+}
+
+func (b *GCS) WriteIf(key string, data []byte, contentType, version string) error {
+	conditions := storage.Conditions{DoesNotExist: true}
+	if version != "" {
+		generation, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return err
+		}
+		conditions = storage.Conditions{GenerationMatch: generation}
+	}
+
+	err := b.write(b.client.Bucket(b.bucket).Object(key).If(conditions), data, contentType)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+func (b *GCS) write(obj *storage.ObjectHandle, data []byte, contentType string) error {
+	w := obj.NewWriter(context.Background())
+	w.ContentType = contentType
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *GCS) List(prefix string) ([]string, error) {
+	var keys []string
+	it := b.client.Bucket(b.bucket).Objects(context.Background(), &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+}
+
+func (b *GCS) Delete(key string) error {
+	err := b.client.Bucket(b.bucket).Object(key).Delete(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *GCS) DeleteIf(key, version string) error {
+	generation, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return err
+	}
+	err = b.client.Bucket(b.bucket).Object(key).If(storage.Conditions{GenerationMatch: generation}).Delete(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+func (b *GCS) String() string {
+	return "gs://" + b.bucket
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Local stores objects as files below a root directory. The version of an
+// object is the SHA-256 of its content.
+type Local struct {
+	root string
+	mu   sync.Mutex
+}
+
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+func (b *Local) path(key string) string {
+	return filepath.Join(b.root, filepath.FromSlash(key))
+}
+
+func (b *Local) Read(key string) (*Object, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, Version: checksum(data)}, nil
+}
+
+func (b *Local) Write(key string, data []byte, contentType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.write(key, data)
+}
+
+func (b *Local) WriteIf(key string, data []byte, contentType, version string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, err := b.Read(key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if version != "" {
+			return ErrPreconditionFailed
+		}
+	case err != nil:
+		return err
+	case current.Version != version:
+		return ErrPreconditionFailed
+	}
+	return b.write(key, data)
+}
+
+// write replaces the file atomically by renaming a temporary file over it.
+func (b *Local) write(key string, data []byte) error {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (b *Local) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(b.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (b *Local) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := os.Remove(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *Local) DeleteIf(key, version string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, err := b.Read(key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case current.Version != version:
+		return ErrPreconditionFailed
+	}
+	err = os.Remove(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *Local) String() string {
+	return "file://" + b.root
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
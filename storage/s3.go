@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io"
+	"kubecost-efficiency-fetcher/configs"
+)
+
+// S3 stores objects in an S3 (or S3-compatible) bucket.
+type S3 struct {
+	svc    *s3.S3
+	bucket string
+}
+
+func NewS3(svc *s3.S3, bucket string) *S3 {
+	return &S3{svc: svc, bucket: bucket}
+}
+
+// Read returns ErrNotFound for a missing key. Without s3:ListBucket, S3 answers
+// 403 instead of 404 for a missing key, so a 403 is checked with a listing: a key
+// that is not listed is missing, and a listing that is denied as well is reported
+// as the missing permission rather than taken for an empty history.
+func (b *S3) Read(key string) (*Object, error) {
+	resp, err := b.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.RequestFailure); ok {
+		switch {
+		case aerr.StatusCode() == 404 || aerr.Code() == s3.ErrCodeNoSuchKey:
+			return nil, ErrNotFound
+		case aerr.StatusCode() == 403:
+			return nil, b.checkMissing(key, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, Version: aws.StringValue(resp.ETag)}, nil
+}
+
+func (b *S3) Write(key string, data []byte, contentType string) error {
+	_, err := b.svc.PutObject(configs.NewPutObjectInput(b.bucket, key, data, contentType))
+	return err
+}
+
+// WriteIf uses the If-Match / If-None-Match conditional write headers. The SDK
+// does not model them on PutObjectInput, so they are set on the HTTP request.
+func (b *S3) WriteIf(key string, data []byte, contentType, version string) error {
+	req, _ := b.svc.PutObjectRequest(configs.NewPutObjectInput(b.bucket, key, data, contentType))
+	req.Handlers.Build.PushBack(func(r *request.Request) {
+		if version == "" {
+			r.HTTPRequest.Header.Set("If-None-Match", "*")
+		} else {
+			r.HTTPRequest.Header.Set("If-Match", version)
+		}
+	})
+	err := req.Send()
+	if aerr, ok := err.(awserr.RequestFailure); ok && (aerr.StatusCode() == 412 || aerr.Code() == "ConditionalRequestConflict") {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+// checkMissing returns ErrNotFound when key is not listed, and err otherwise.
+func (b *S3) checkMissing(key string, err error) error {
+	out, listErr := b.svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(b.bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int64(1),
+	})
+	if listErr != nil {
+		return fmt.Errorf("reading %s was denied and the bucket cannot be listed, the credentials need s3:GetObject and s3:ListBucket: %w", key, err)
+	}
+	if len(out.Contents) == 0 || aws.StringValue(out.Contents[0].Key) != key {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (b *S3) List(prefix string) ([]string, error) {
+	var keys []string
+	err := b.svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	return keys, err
+}
+
+func (b *S3) Delete(key string) error {
+	_, err := b.svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// DeleteIf sets the If-Match header on the DeleteObject request, like WriteIf.
+// S3-compatible stores that ignore the header delete unconditionally.
+func (b *S3) DeleteIf(key, version string) error {
+	req, _ := b.svc.DeleteObjectRequest(&s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	req.Handlers.Build.PushBack(func(r *request.Request) {
+		r.HTTPRequest.Header.Set("If-Match", version)
+	})
+	err := req.Send()
+	if aerr, ok := err.(awserr.RequestFailure); ok {
+		switch {
+		case aerr.StatusCode() == 404:
+			return nil
+		case aerr.StatusCode() == 412 || aerr.Code() == "ConditionalRequestConflict":
+			return ErrPreconditionFailed
+		}
+	}
+	return err
+}
+
+func (b *S3) String() string {
+	return "s3://" + b.bucket
+}
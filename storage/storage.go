@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"kubecost-efficiency-fetcher/configs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrNotFound is returned by Read when the object does not exist.
+	ErrNotFound = errors.New("object not found")
+
+	// ErrPreconditionFailed is returned by WriteIf when the object was created or
+	// modified after it was read.
+	ErrPreconditionFailed = errors.New("object was modified concurrently")
+)
+
+// Object is the content of a stored object together with an opaque version
+// (an ETag or generation) that can be passed to WriteIf.
+type Object struct {
+	Data    []byte
+	Version string
+}
+
+// Backend is where the collectors keep their history. Keys are slash separated
+// paths such as "Pod/Pod.csv".
+type Backend interface {
+	// Read returns the object stored at key, or ErrNotFound.
+	Read(key string) (*Object, error)
+
+	// Write creates or replaces the object stored at key.
+	Write(key string, data []byte, contentType string) error
+
+	// WriteIf writes the object only if its current version equals version. An
+	// empty version requires that the object does not exist yet. ErrPreconditionFailed
+	// is returned otherwise.
+	WriteIf(key string, data []byte, contentType, version string) error
+
+	// List returns the keys of all objects whose key starts with prefix.
+	List(prefix string) ([]string, error)
+
+	// Delete removes the object stored at key. Deleting a missing object is not an error.
+	Delete(key string) error
+
+	// DeleteIf removes the object only if its current version equals version, and
+	// returns ErrPreconditionFailed otherwise. Deleting a missing object is not an error.
+	DeleteIf(key, version string) error
+
+	// String describes the backend for log messages, e.g. "s3://bucket".
+	String() string
+}
+
+// New creates the backend selected by configs.StorageBackend.
+func New() (Backend, error) {
+	switch configs.StorageBackend {
+	case "s3":
+		if configs.Svc == nil {
+			return nil, errors.New("S3 client is not available")
+		}
+		return NewS3(configs.Svc, configs.BucketName), nil
+	case "local":
+		return NewLocal(configs.LocalStorageDir)
+	case "gcs":
+		return NewGCS(configs.BucketName, configs.GCSEndpoint)
+	case "azure":
+		return NewAzure(configs.BucketName, configs.AzureConnectionString)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", configs.StorageBackend)
+}
+
+// WriteLocalCopy writes the local copy OutputDir/<name> of a history object. The
+// local backend already keeps the object itself on disk, so it writes no copy.
+func WriteLocalCopy(name string, data []byte) error {
+	if configs.StorageBackend == "local" {
+		return nil
+	}
+	if err := os.MkdirAll(configs.OutputDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(configs.OutputDir, name), data, 0644)
+}
@@ -0,0 +1,123 @@
+package wasters
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"path"
+	"sort"
+)
+
+// Filter selects the workloads that are ranked.
+type Filter struct {
+	// MinCost drops workloads that cost less over the period, however inefficient.
+	MinCost float64
+	// Namespaces keeps only these namespaces, given as names or path.Match patterns
+	// such as "team-a-*". Empty keeps every namespace.
+	Namespaces []string
+	// ExcludeNamespaces drops these namespaces, e.g. "kube-system".
+	ExcludeNamespaces []string
+}
+
+func matchAny(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keep reports whether a workload of the given namespace and cost passes the filter.
+func (f Filter) keep(namespace string, cost float64) bool {
+	if cost < f.MinCost {
+		return false
+	}
+	if len(f.Namespaces) > 0 && !matchAny(f.Namespaces, namespace) {
+		return false
+	}
+	return !matchAny(f.ExcludeNamespaces, namespace)
+}
+
+// Waster is the cost and waste of one workload summed over a period.
+type Waster struct {
+	Aggregation string
+	Cluster     string
+	Namespace   string
+	Name        string
+
+	TotalCost, CpuCost, RamCost float64
+	CpuWaste, RamWaste          float64
+}
+
+// Waste is the CPU and RAM waste of the workload.
+func (w Waster) Waste() float64 {
+	return w.CpuWaste + w.RamWaste
+}
+
+// Rank sums the allocations, which must all be of the same aggregation, by cluster,
+// namespace and name, and returns the topN that pass the filter ranked by waste.
+// A topN of 0 returns all of them.
+func Rank(allocations []allocation.Allocation, f Filter, topN int) []Waster {
+	byName := map[[3]string]*Waster{}
+	for _, a := range allocations {
+		key := [3]string{a.Cluster, a.Namespace, a.Name}
+		w, ok := byName[key]
+		if !ok {
+			w = &Waster{Aggregation: a.Aggregation, Cluster: a.Cluster, Namespace: a.Namespace, Name: a.Name}
+			byName[key] = w
+		}
+		w.TotalCost += a.TotalCost
+		w.CpuCost += a.CpuCost
+		w.RamCost += a.RamCost
+		w.CpuWaste += a.CpuWaste
+		w.RamWaste += a.RamWaste
+	}
+
+	var ranked []Waster
+	for _, w := range byName {
+		if w.Waste() > 0 && f.keep(w.Namespace, w.TotalCost) {
+			ranked = append(ranked, *w)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Waste() != ranked[j].Waste() {
+			return ranked[i].Waste() > ranked[j].Waste()
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if topN > 0 && len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+	return ranked
+}
+
+// Header is the header of the top wasters CSV. Efficiencies are the cost-weighted
+// averages of the period, in percent.
+var Header = []string{
+	"Aggregation", "ClusterName", "Namespace", "Name",
+	"Total Cost", "Cpu Cost", "Cpu Efficiency", "Cpu Waste", "Ram Cost", "Ram Efficiency", "Ram Waste", "Total Waste",
+}
+
+// Records returns the wasters as CSV records, without the header.
+func Records(wasters []Waster) [][]string {
+	records := make([][]string, 0, len(wasters))
+	for _, w := range wasters {
+		records = append(records, []string{
+			w.Aggregation, w.Cluster, w.Namespace, w.Name,
+			fmt.Sprintf("%f", w.TotalCost),
+			fmt.Sprintf("%f", w.CpuCost), fmt.Sprintf("%f", efficiency(w.CpuCost, w.CpuWaste)), fmt.Sprintf("%f", w.CpuWaste),
+			fmt.Sprintf("%f", w.RamCost), fmt.Sprintf("%f", efficiency(w.RamCost, w.RamWaste)), fmt.Sprintf("%f", w.RamWaste),
+			fmt.Sprintf("%f", w.Waste()),
+		})
+	}
+	return records
+}
+
+// efficiency recovers the cost-weighted efficiency of a period from its cost and
+// waste. Usage above the request has no waste, so it is at most 100%.
+func efficiency(cost, waste float64) float64 {
+	if cost == 0 {
+		return 0
+	}
+	return (1 - waste/cost) * 100
+}
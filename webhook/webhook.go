@@ -0,0 +1,274 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/schema"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Kubecost-Signature-256"
+	EventHeader     = "X-Kubecost-Event"
+
+	EventAllocations = "allocations"
+	EventRunFinished = "run.finished"
+	EventBudgetAlert = "budget.alert"
+)
+
+// Config holds the settings of the webhook sink.
+type Config struct {
+	URLs           []string
+	Secret         string // HMAC-SHA256 key of the signature header, required
+	SendRecords    bool   // POST batches of allocation records
+	SendSummary    bool   // POST a run summary when the run finishes
+	BatchSize      int
+	MaxRetries     int
+	DeadLetterFile string
+
+	RunID   string
+	Cluster string
+	Window  string
+}
+
+// Batch is the body of an allocations event.
+type Batch struct {
+	Event       string                  `json:"event"`
+	RunID       string                  `json:"runId"`
+	Cluster     string                  `json:"cluster"`
+	Window      string                  `json:"window"`
+	Aggregation string                  `json:"aggregation"`
+	Allocations []allocation.Allocation `json:"allocations"`
+}
+
+// AggregationSummary totals the rows of one aggregation in a run.
+type AggregationSummary struct {
+	Rows      int     `json:"rows"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+// Summary is the body of a run.finished event. Missing lists the aggregations
+// no data was delivered for, e.g. because their collector failed.
+type Summary struct {
+	Event        string                        `json:"event"`
+	RunID        string                        `json:"runId"`
+	Cluster      string                        `json:"cluster"`
+	Window       string                        `json:"window"`
+	Complete     bool                          `json:"complete"`
+	Missing      []string                      `json:"missing"`
+	Aggregations map[string]AggregationSummary `json:"aggregations"`
+}
+
+// DeadLetter is one line of the dead-letter file.
+type DeadLetter struct {
+	URL      string          `json:"url"`
+	Event    string          `json:"event"`
+	Body     json.RawMessage `json:"body"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// Sink delivers signed JSON events to every configured URL.
+type Sink struct {
+	config Config
+	client *http.Client
+
+	mu      sync.Mutex
+	summary map[string]AggregationSummary
+}
+
+// Open refuses an empty secret, so that events are never sent unsigned.
+func Open(config Config) (*Sink, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("webhook secret is empty, set WebhookSecret so receivers can verify the requests")
+	}
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("webhook batch size must be positive")
+	}
+	return &Sink{config: config, client: &http.Client{Timeout: 30 * time.Second}, summary: map[string]AggregationSummary{}}, nil
+}
+
+func (s *Sink) Name() string {
+	return "webhook"
+}
+
+// Sign returns the signature header value of body: "sha256=" and the hex HMAC-SHA256
+// of the body with secret as key. Receivers compute the same value to verify it.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	var total float64
+	for _, a := range allocations {
+		total += a.TotalCost
+	}
+	s.mu.Lock()
+	s.summary[aggregation] = AggregationSummary{Rows: len(allocations), TotalCost: total}
+	s.mu.Unlock()
+
+	if !s.config.SendRecords {
+		return nil
+	}
+
+	var errs []error
+	for start := 0; start < len(allocations); start += s.config.BatchSize {
+		end := start + s.config.BatchSize
+		if end > len(allocations) {
+			end = len(allocations)
+		}
+		body, err := json.Marshal(Batch{
+			Event:       EventAllocations,
+			RunID:       s.config.RunID,
+			Cluster:     s.config.Cluster,
+			Window:      s.config.Window,
+			Aggregation: aggregation,
+			Allocations: allocations[start:end],
+		})
+		if err != nil {
+			return err
+		}
+		errs = append(errs, s.deliver(EventAllocations, body))
+	}
+	return errors.Join(errs...)
+}
+
+// Close sends the run summary.
+func (s *Sink) Close() error {
+	defer s.client.CloseIdleConnections()
+	if !s.config.SendSummary {
+		return nil
+	}
+
+	s.mu.Lock()
+	summary := Summary{
+		Event:        EventRunFinished,
+		RunID:        s.config.RunID,
+		Cluster:      s.config.Cluster,
+		Window:       s.config.Window,
+		Missing:      []string{},
+		Aggregations: s.summary,
+	}
+	for _, aggregation := range schema.Aggregations {
+		if _, ok := s.summary[aggregation]; !ok {
+			summary.Missing = append(summary.Missing, aggregation)
+		}
+	}
+	summary.Complete = len(summary.Missing) == 0
+	body, err := json.Marshal(summary)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.deliver(EventRunFinished, body)
+}
+
+// Send delivers an event that is not produced by the sink itself, such as a budget
+// alert, with the same signature, retries and dead-letter file.
+func (s *Sink) Send(event string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.deliver(event, body)
+}
+
+// deliver posts body to every URL. Deliveries that fail after the retries are
+// appended to the dead-letter file.
+func (s *Sink) deliver(event string, body []byte) error {
+	var errs []error
+	for _, url := range s.config.URLs {
+		err := s.post(url, event, body)
+		if err == nil {
+			continue
+		}
+		if dlErr := s.deadLetter(url, event, body, err); dlErr != nil {
+			errs = append(errs, fmt.Errorf("delivery to %s failed: %w, and writing the dead letter failed: %v", url, err, dlErr))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("delivery to %s failed, written to %s: %w", url, s.config.DeadLetterFile, err))
+	}
+	return errors.Join(errs...)
+}
+
+// post sends one request. Network errors, 429 and 5xx responses are retried with
+// an exponential backoff; other responses mean the receiver rejected the event.
+func (s *Sink) post(url, event string, body []byte) error {
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = s.send(url, event, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Sink) send(url, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, Sign(s.config.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	err = errors.New(resp.Status)
+	if message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096)); len(bytes.TrimSpace(message)) > 0 {
+		err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
+
+func (s *Sink) deadLetter(url, event string, body []byte, deliveryErr error) error {
+	line, err := json.Marshal(DeadLetter{URL: url, Event: event, Body: body, Error: deliveryErr.Error(), FailedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.config.DeadLetterFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.config.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"kubecost-efficiency-fetcher/allocation"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// receiver records the requests it gets and answers with the next status of
+// statuses, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	events   []string
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.Header.Get(SignatureHeader) != Sign("secret", body) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	r.events = append(r.events, req.Header.Get(EventHeader))
+	r.bodies = append(r.bodies, body)
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		http.Error(w, "try again", status)
+	}
+}
+
+func testConfig(t *testing.T, url string) Config {
+	return Config{
+		URLs:           []string{url},
+		Secret:         "secret",
+		SendRecords:    true,
+		SendSummary:    true,
+		BatchSize:      2,
+		DeadLetterFile: filepath.Join(t.TempDir(), "dead-letter.jsonl"),
+		RunID:          "run-1",
+		Cluster:        "prod",
+		Window:         "2024-10-01T00:00:00Z,2024-10-02T00:00:00Z",
+	}
+}
+
+func TestOpenRequiresSecret(t *testing.T) {
+	config := testConfig(t, "http://localhost")
+	config.Secret = ""
+	if _, err := Open(config); err == nil {
+		t.Error("opened without a secret")
+	}
+}
+
+func TestSink(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	s, err := Open(testConfig(t, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := []allocation.Allocation{{Name: "a", TotalCost: 1}, {Name: "b", TotalCost: 2}, {Name: "c", TotalCost: 3}}
+	if err := s.Write("Pod", pods); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{EventAllocations, EventAllocations, EventRunFinished}
+	if strings.Join(r.events, ",") != strings.Join(want, ",") {
+		t.Fatalf("got events %v, want %v", r.events, want)
+	}
+	var batch Batch
+	if err := json.Unmarshal(r.bodies[1], &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Aggregation != "Pod" || len(batch.Allocations) != 1 || batch.Allocations[0].Name != "c" {
+		t.Errorf("second batch %+v", batch)
+	}
+	var summary Summary
+	if err := json.Unmarshal(r.bodies[2], &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Complete || summary.Aggregations["Pod"] != (AggregationSummary{Rows: 3, TotalCost: 6}) {
+		t.Errorf("summary %+v", summary)
+	}
+	for _, missing := range summary.Missing {
+		if missing == "Pod" {
+			t.Error("Pod reported missing")
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	config := testConfig(t, server.URL)
+	config.MaxRetries = 1
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(EventBudgetAlert, map[string]string{"budget": "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.events) != 2 {
+		t.Errorf("got %d requests, want 2", len(r.events))
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	config := testConfig(t, server.URL)
+	config.MaxRetries = 3
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(EventBudgetAlert, map[string]string{"budget": "payments"}); err == nil {
+		t.Fatal("rejected delivery returned no error")
+	}
+	if len(r.events) != 1 {
+		t.Errorf("rejected delivery was retried: %d requests", len(r.events))
+	}
+
+	data, err := os.ReadFile(config.DeadLetterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		t.Fatal(err)
+	}
+	if letter.URL != server.URL || letter.Event != EventBudgetAlert || string(letter.Body) != `{"budget":"payments"}` || !strings.HasPrefix(letter.Error, "400") {
+		t.Errorf("dead letter %+v", letter)
+	}
+}
@@ -0,0 +1,324 @@
+package xlsx
+
+import (
+	"fmt"
+	"kubecost-efficiency-fetcher/allocation"
+	"kubecost-efficiency-fetcher/schema"
+	"kubecost-efficiency-fetcher/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Sink collects the allocations of a run and writes them, when the run finishes,
+// to a workbook with a summary sheet and one sheet per aggregation.
+type Sink struct {
+	store     storage.Backend
+	key       string
+	localPath string
+	cluster   string
+	window    string
+	runID     string
+
+	mu          sync.Mutex
+	allocations map[string][]allocation.Allocation
+}
+
+// NewSink returns a sink that writes the workbook to key in store and to localPath.
+func NewSink(store storage.Backend, key, localPath, cluster, window, runID string) *Sink {
+	return &Sink{
+		store:       store,
+		key:         key,
+		localPath:   localPath,
+		cluster:     cluster,
+		window:      window,
+		runID:       runID,
+		allocations: map[string][]allocation.Allocation{},
+	}
+}
+
+func (s *Sink) Name() string {
+	return "xlsx"
+}
+
+func (s *Sink) Write(aggregation string, allocations []allocation.Allocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allocations[aggregation] = append(s.allocations[aggregation], allocations...)
+	return nil
+}
+
+// Close builds the workbook and writes it.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := Workbook(s.allocations, s.cluster, s.window, s.runID)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.localPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.localPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return s.store.Write(s.key, buf.Bytes(), contentType)
+}
+
+// isEfficiency reports whether a column holds a percentage.
+func isEfficiency(column string) bool {
+	return strings.HasSuffix(column, "Efficiency")
+}
+
+// isCost reports whether a column holds a cost or a waste amount.
+func isCost(column string) bool {
+	return strings.HasSuffix(column, "Cost") || strings.HasSuffix(column, "Waste")
+}
+
+// cell returns the value of a header column for an allocation: a float64 for
+// costs, a fraction for efficiencies (formatted as percent) and a string otherwise.
+func cell(aggregation, column string, a allocation.Allocation) interface{} {
+	switch column {
+	case aggregation:
+		return a.Name
+	case "ClusterName":
+		return a.Cluster
+	case "Region":
+		return a.Region
+	case "Namespace":
+		return a.Namespace
+	case "Window Start":
+		return a.WindowStart
+	case "Window End":
+		return a.WindowEnd
+	case "Cpu Cost":
+		return a.CpuCost
+	case "Gpu Cost":
+		return a.GpuCost
+	case "Ram Cost":
+		return a.RamCost
+	case "PV Cost":
+		return a.PVCost
+	case "Network Cost":
+		return a.NetworkCost
+	case "LoadBalancer Cost":
+		return a.LoadBalancerCost
+	case "Total Cost":
+		return a.TotalCost
+	case "Cpu Efficiency":
+		return a.CpuEfficiency / 100
+	case "Ram Efficiency":
+		return a.RamEfficiency / 100
+	case "Total Efficiency":
+		return a.TotalEfficiency / 100
+	case "Cpu Request Average":
+		return a.CpuCoreRequestAverage
+	case "Cpu Usage Average":
+		return a.CpuCoreUsageAverage
+	case "Ram Request Average":
+		return a.RamBytesRequestAverage
+	case "Ram Usage Average":
+		return a.RamBytesUsageAverage
+	case "Cpu Waste":
+		return a.CpuWaste
+	case "Ram Waste":
+		return a.RamWaste
+	}
+	return ""
+}
+
+type styles struct {
+	header, cost, percent int
+}
+
+func newStyles(f *excelize.File) (styles, error) {
+	var s styles
+	var err error
+	if s.header, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	}); err != nil {
+		return s, err
+	}
+	costFormat := "#,##0.00"
+	if s.cost, err = f.NewStyle(&excelize.Style{CustomNumFmt: &costFormat}); err != nil {
+		return s, err
+	}
+	// Built-in number format 10 is "0.00%".
+	if s.percent, err = f.NewStyle(&excelize.Style{NumFmt: 10}); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// Workbook creates the workbook of a run: a Summary sheet with the totals of every
+// aggregation, then one sheet per aggregation with the CSV columns, typed numeric
+// cells, percentage efficiencies, a frozen header row and an autofilter.
+func Workbook(allocations map[string][]allocation.Allocation, cluster, window, runID string) (*excelize.File, error) {
+	f := excelize.NewFile()
+	st, err := newStyles(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.SetSheetName("Sheet1", "Summary"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := summarySheet(f, st, allocations, cluster, window, runID); err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, aggregation := range schema.Aggregations {
+		if err := aggregationSheet(f, st, aggregation, allocations[aggregation]); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s sheet: %w", aggregation, err)
+		}
+	}
+	return f, nil
+}
+
+// table writes a header row and the rows below it, styles the columns and adds
+// the frozen header and the autofilter.
+func table(f *excelize.File, st styles, sheet string, firstRow int, header []string, rows [][]interface{}) error {
+	for i, column := range header {
+		cellName, _ := excelize.CoordinatesToCellName(i+1, firstRow)
+		if err := f.SetCellValue(sheet, cellName, column); err != nil {
+			return err
+		}
+	}
+	for r, row := range rows {
+		cellName, _ := excelize.CoordinatesToCellName(1, firstRow+1+r)
+		if err := f.SetSheetRow(sheet, cellName, &row); err != nil {
+			return err
+		}
+	}
+
+	lastRow := firstRow + len(rows)
+	first, _ := excelize.CoordinatesToCellName(1, firstRow)
+	last, _ := excelize.CoordinatesToCellName(len(header), firstRow)
+	if err := f.SetCellStyle(sheet, first, last, st.header); err != nil {
+		return err
+	}
+	for i, column := range header {
+		name, _ := excelize.ColumnNumberToName(i + 1)
+		width := float64(len(column) + 4)
+		if i == 0 {
+			width = 40
+		}
+		if err := f.SetColWidth(sheet, name, name, width); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		style := 0
+		switch {
+		case isEfficiency(column):
+			style = st.percent
+		case isCost(column):
+			style = st.cost
+		default:
+			continue
+		}
+		top, _ := excelize.CoordinatesToCellName(i+1, firstRow+1)
+		bottom, _ := excelize.CoordinatesToCellName(i+1, lastRow)
+		if err := f.SetCellStyle(sheet, top, bottom, style); err != nil {
+			return err
+		}
+	}
+
+	topLeft, _ := excelize.CoordinatesToCellName(1, firstRow+1)
+	if err := f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      firstRow,
+		TopLeftCell: topLeft,
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	bottomRight, _ := excelize.CoordinatesToCellName(len(header), lastRow)
+	return f.AutoFilter(sheet, first+":"+bottomRight, nil)
+}
+
+func aggregationSheet(f *excelize.File, st styles, aggregation string, allocations []allocation.Allocation) error {
+	if _, err := f.NewSheet(aggregation); err != nil {
+		return err
+	}
+	header := schema.Header(aggregation)
+	rows := make([][]interface{}, 0, len(allocations))
+	for _, a := range allocations {
+		row := make([]interface{}, len(header))
+		for i, column := range header {
+			row[i] = cell(aggregation, column, a)
+		}
+		rows = append(rows, row)
+	}
+	return table(f, st, aggregation, 1, header, rows)
+}
+
+// summarySheet lists the run and, per aggregation, the number of rows, the cost
+// totals and the efficiencies, weighted by the CPU, RAM and total cost
+// respectively. Aggregations overlap (every pod is also in a namespace), so their
+// totals must not be added up.
+func summarySheet(f *excelize.File, st styles, allocations map[string][]allocation.Allocation, cluster, window, runID string) error {
+	info := [][]interface{}{{"Cluster", cluster}, {"Window", window}, {"Run", runID}}
+	for i, row := range info {
+		cellName, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Summary", cellName, &row); err != nil {
+			return err
+		}
+	}
+	if err := f.SetCellStyle("Summary", "A1", fmt.Sprintf("A%d", len(info)), st.header); err != nil {
+		return err
+	}
+
+	header := []string{"Aggregation", "Rows", "Cpu Cost", "Gpu Cost", "Ram Cost", "PV Cost", "Network Cost", "LoadBalancer Cost", "Total Cost", "Cpu Efficiency", "Ram Efficiency", "Total Efficiency", "Cpu Waste", "Ram Waste"}
+	var rows [][]interface{}
+	for _, aggregation := range schema.Aggregations {
+		var t allocation.Allocation
+		for _, a := range allocations[aggregation] {
+			t.CpuCost += a.CpuCost
+			t.GpuCost += a.GpuCost
+			t.RamCost += a.RamCost
+			t.PVCost += a.PVCost
+			t.NetworkCost += a.NetworkCost
+			t.LoadBalancerCost += a.LoadBalancerCost
+			t.TotalCost += a.TotalCost
+			t.CpuEfficiency += a.CpuEfficiency * a.CpuCost
+			t.RamEfficiency += a.RamEfficiency * a.RamCost
+			t.TotalEfficiency += a.TotalEfficiency * a.TotalCost
+			t.CpuWaste += a.CpuWaste
+			t.RamWaste += a.RamWaste
+		}
+		if t.CpuCost > 0 {
+			t.CpuEfficiency /= t.CpuCost
+		}
+		if t.RamCost > 0 {
+			t.RamEfficiency /= t.RamCost
+		}
+		if t.TotalCost > 0 {
+			t.TotalEfficiency /= t.TotalCost
+		}
+		rows = append(rows, []interface{}{
+			aggregation, len(allocations[aggregation]),
+			t.CpuCost, t.GpuCost, t.RamCost, t.PVCost, t.NetworkCost, t.LoadBalancerCost, t.TotalCost,
+			t.CpuEfficiency / 100, t.RamEfficiency / 100, t.TotalEfficiency / 100,
+			t.CpuWaste, t.RamWaste,
+		})
+	}
+	return table(f, st, "Summary", len(info)+2, header, rows)
+}